@@ -1,6 +1,7 @@
 package app
 
 import (
+	"bufio"
 	"compress/gzip"
 	"database/sql"
 	"io"
@@ -70,8 +71,47 @@ func appendToMonthlyArchive(cfg Config, date, srcPath string) error {
 	defer out.Close()
 
 	gw := gzip.NewWriter(out)
+	gw.Name = filepath.Base(srcPath) // 每天一个 gzip member，按文件名可单独读回
 	defer gw.Close()
 
 	_, err = io.Copy(gw, in)
 	return err
 }
+
+// readArchivedDay：从 <month>.jsonl.gz 中读出某一天的原始日志
+// 旧版本归档的 member 没有文件名，无法区分日期，会被跳过
+func readArchivedDay(cfg Config, date string) ([]byte, error) {
+	if len(date) < len("2006-01") {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(filepath.Join(cfg.ArchiveDir, date[:7]+".jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	want := date + ".jsonl"
+	for {
+		zr.Multistream(false)
+		if zr.Name == want {
+			return io.ReadAll(zr)
+		}
+		if _, err := io.Copy(io.Discard, zr); err != nil {
+			return nil, err
+		}
+
+		if err := zr.Reset(br); err == io.EOF {
+			return nil, os.ErrNotExist
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -2,8 +2,10 @@ package app
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 /*
@@ -12,57 +14,136 @@ Public API
 ========================
 */
 
+// AskResult：/ask --json 的输出结构（供脚本 / 编辑器插件使用）
+type AskResult struct {
+	Answer     string         `json:"answer"`
+	References []AskReference `json:"references"`
+	Model      string         `json:"model"`
+	Latency    float64        `json:"latency"` // 秒：检索 + 生成
+
+	Scope            string   `json:"scope,omitempty"`   // 解析出的时间范围
+	AsOf             string   `json:"as_of,omitempty"`   // --as-of 模拟日期
+	Grounded         bool     `json:"grounded"`          // 至少引用了一条记忆
+	InvalidCitations []int    `json:"invalid_citations"` // 不存在的记忆编号
+	Uncited          []string `json:"uncited"`           // 没有标注引用的论断
+}
+
+type AskReference struct {
+	N     int     `json:"n"` // prompt 中的记忆编号
+	Type  string  `json:"type"`
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+	Text  string  `json:"text"`
+	Cited bool    `json:"cited"`
+
+	// Type == "raw"：原始对话证据的出处
+	Source string `json:"source,omitempty"` // 日志文件（或归档中的 member）
+	Lines  string `json:"lines,omitempty"`  // L12-L15
+}
+
+const askNoMemoryAnswer = "我没有在你的历史记录中找到相关内容，因此无法基于记忆回答这个问题。"
+
 // Ask answers a question based on user's historical summaries.
-// Default: show Top-1 reference
-// With --refs: show Top-N references (appendix)
-func Ask(db *sql.DB, cfg Config, input string) (string, error) {
-	question, showRefs := parseAskArgs(input)
+// Default: stream the answer (same renderer as chat), then return the cited references
+// With --refs: also return Top-N references (appendix)
+// With --json: no streaming, return AskResult as JSON
+// 问题与回答（连同被引用的记忆）以 kind = ask 写入 raw log
+func Ask(lw *LogWriter, db *sql.DB, cfg Config, input string) (string, error) {
+	args := parseAskArgs(input)
+	started := time.Now()
+
+	asOf, err := resolveAsOf(cfg, args.AsOf)
+	if err != nil {
+		return "", err
+	}
 
-	// 1. semantic search
-	hits, err := SearchWithScore(db, cfg, question)
+	// 1. semantic search（问题中的时间表达 → 检索时间范围；--as-of 只用之前的记忆）
+	query, opts := scopeQuery(cfg, args.Question, asOf)
+	hits, err := RetrieveMemories(db, cfg, query, opts)
 	if err != nil {
 		return "", err
 	}
 	if len(hits) == 0 {
-		return "我没有在你的历史记录中找到相关内容，因此无法基于记忆回答这个问题。", nil
+		answer := askNoMemoryAnswer
+		if opts.Scope != nil {
+			answer = fmt.Sprintf("我没有在你 %s ~ %s 的记录中找到相关内容，因此无法基于记忆回答这个问题。",
+				opts.Scope.StartDate(), opts.Scope.EndDate())
+		}
+		writeAskRecords(lw, args.Question, answer, nil)
+		if args.JSON {
+			return marshalAskResult(AskResult{
+				Answer:  answer,
+				Model:   chatModel,
+				Latency: time.Since(started).Seconds(),
+				Scope:   formatScope(opts.Scope),
+				AsOf:    args.AsOf,
+			})
+		}
+		return answer, nil
 	}
 
-	// 2. build memory context (TopK for reasoning)
-	var ctx strings.Builder
-	ctx.WriteString("以下是我在你过去记录中找到的相关内容：\n\n")
+	// 2. build memory context (TopK for reasoning)，每段记忆编号 [n]
+	hits = truncateHits(hits, cfg.SearchTopK)
+	// 2b. drill-down：daily 命中 → 当天原始对话中的相关轮次（作为可引用的证据）
+	hits = append(hits, drillDown(cfg, hits, query)...)
+	prompt := askPrompt(hits, opts, "", args.Question)
 
-	for i, h := range hits {
-		if i >= cfg.SearchTopK {
-			break
+	// 3a. JSON：非流式，一次性返回
+	if args.JSON {
+		answer, err := callLLMNonStream(prompt)
+		if err != nil {
+			return "", err
 		}
-		ctx.WriteString(fmt.Sprintf(
-			"- [%s %s | score %.2f]\n%s\n\n",
-			h.Date,
-			h.Type,
-			h.Score,
-			h.Text,
-		))
-	}
 
-	// 3. compose prompt
-	prompt := buildAskPrompt(ctx.String(), question)
+		check := checkCitations(answer, len(hits))
+		writeAskRecords(lw, args.Question, answer, citedRefs(hits, check))
+		cited := make(map[int]bool, len(check.Cited))
+		for _, n := range check.Cited {
+			cited[n] = true
+		}
 
-	// 4. call LLM
-	answer, err := callLLMNonStream(prompt)
+		refs := make([]AskReference, 0, len(hits))
+		for i, h := range hits {
+			ref := AskReference{
+				N:     i + 1,
+				Type:  h.Type,
+				Key:   h.Date,
+				Score: h.Score,
+				Text:  h.Text,
+				Cited: cited[i+1],
+			}
+			if h.Type == "raw" {
+				ref.Source = h.Doc
+				ref.Lines = lineRange(h)
+			}
+			refs = append(refs, ref)
+		}
+		return marshalAskResult(AskResult{
+			Answer:           answer,
+			References:       refs,
+			Model:            chatModel,
+			Latency:          time.Since(started).Seconds(),
+			Scope:            formatScope(opts.Scope),
+			AsOf:             args.AsOf,
+			Grounded:         check.Grounded,
+			InvalidCitations: check.Invalid,
+			Uncited:          check.Uncited,
+		})
+	}
+
+	// 3b. 默认：流式输出（与 chat 相同的 renderer），结束后再返回引用块
+	answer, check, err := streamAnswer(prompt, len(hits))
 	if err != nil {
 		return "", err
 	}
+	writeAskRecords(lw, args.Question, answer, citedRefs(hits, check))
 
-	// 5. append references
+	// 4. references：只列出被引用的记忆
 	var out strings.Builder
-	out.WriteString(answer)
-
-	// Top-1 reference (always)
-	out.WriteString("\n\n——\n")
-	out.WriteString(formatTopReference(hits[0]))
+	out.WriteString(formatCitationFooter(hits, check))
 
 	// Optional appendix (Top-N)
-	if showRefs {
+	if args.ShowRefs {
 		out.WriteString("\n\n附录 · 相关记录（最多 10 条）：\n")
 		max := min(10, len(hits))
 		for i := 0; i < max; i++ {
@@ -72,31 +153,144 @@ func Ask(db *sql.DB, cfg Config, input string) (string, error) {
 	}
 
 	// ✅ 在这里加 TTS（只读“核心回答”，不是 refs）
-	Speak(answer)
+	Speak(stripCitations(answer))
 	return out.String(), nil
 }
 
+// askPrompt：编号记忆 + 可选的时间范围 / 会话历史（/ask 与 /recall 共用）
+func askPrompt(hits []SearchHit, opts SearchOptions, history, question string) string {
+	memories := formatNumberedMemories(hits)
+	if opts.Scope != nil {
+		memories = fmt.Sprintf("（问题限定的时间范围：%s ~ %s）\n", opts.Scope.StartDate(), opts.Scope.EndDate()) + memories
+	}
+	if !opts.AsOf.IsZero() {
+		memories = fmt.Sprintf("（模拟日期：现在是 %s，你只知道这一天之前的记录，请以当时的视角回答）\n", opts.AsOf.Format("2006-01-02")) + memories
+	}
+	return buildAskPrompt(memories, history, question)
+}
+
+// streamAnswer：流式输出回答，并按 n 条记忆校验引用
+func streamAnswer(prompt string, n int) (string, CitationCheck, error) {
+	answer := streamChatWithContext("", nil, prompt)
+	if strings.TrimSpace(answer) == "" {
+		return "", CitationCheck{}, fmt.Errorf("empty answer from model")
+	}
+	return answer, checkCitations(answer, n), nil
+}
+
+// citedRefs：被回答引用的记忆（"daily 2025-03-01" / "raw L12-L15 2025-03-01"）
+func citedRefs(hits []SearchHit, check CitationCheck) []string {
+	refs := make([]string, 0, len(check.Cited))
+	for _, n := range check.Cited {
+		if n >= 1 && n <= len(hits) {
+			h := hits[n-1]
+			refs = append(refs, hitLabel(h)+" "+h.Date)
+		}
+	}
+	return refs
+}
+
+// writeAskRecords：/ask 与 /recall 的一问一答写入 raw log（user 记录 + 带 refs 的 assistant 记录）
+func writeAskRecords(lw *LogWriter, question, answer string, refs []string) {
+	if err := lw.WriteRecord(LogRecord{Role: "user", Kind: recordKindAsk, Content: question}); err != nil {
+		fmt.Println("[warn] log ask failed:", err)
+		return
+	}
+	if err := lw.WriteRecord(LogRecord{Role: "assistant", Kind: recordKindAsk, Content: answer, Model: chatModel, Refs: refs}); err != nil {
+		fmt.Println("[warn] log ask failed:", err)
+	}
+}
+
+// formatNumberedMemories：[n] 编号与 checkCitations 的校验范围一致（1..len(hits)）
+func formatNumberedMemories(hits []SearchHit) string {
+	var b strings.Builder
+	b.WriteString("以下是我在你过去记录中找到的相关内容（每段前的 [n] 是引用编号）：\n")
+	for _, h := range hits {
+		if h.Type == "raw" {
+			b.WriteString("（raw 为当天原始对话的摘录，行首 L 为原始日志行号）\n")
+			break
+		}
+	}
+	b.WriteString("\n")
+	for i, h := range hits {
+		b.WriteString(fmt.Sprintf(
+			"[%d] %s %s | score %.2f\n%s\n\n",
+			i+1,
+			h.Date,
+			hitLabel(h),
+			h.Score,
+			h.Text,
+		))
+	}
+	return b.String()
+}
+
+// hitLabel：summary 显示类型；原始对话证据显示为 "raw L12-L15"
+func hitLabel(h SearchHit) string {
+	if h.Type == "raw" {
+		return "raw " + lineRange(h)
+	}
+	return h.Type
+}
+
+func formatScope(s *TimeScope) string {
+	if s == nil {
+		return ""
+	}
+	return s.StartDate() + ".." + s.EndDate()
+}
+
+// marshalAskResult：数组字段总是输出 []（没有命中时也一样），便于脚本直接遍历
+func marshalAskResult(r AskResult) (string, error) {
+	if r.References == nil {
+		r.References = []AskReference{}
+	}
+	if r.InvalidCitations == nil {
+		r.InvalidCitations = []int{}
+	}
+	if r.Uncited == nil {
+		r.Uncited = []string{}
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
 /*
 ========================
 Argument Parser
 ========================
 */
 
-func parseAskArgs(input string) (question string, showRefs bool) {
-	parts := strings.Fields(input)
-	if len(parts) == 0 {
-		return "", false
-	}
+type askArgs struct {
+	Question string
+	ShowRefs bool
+	JSON     bool
+	Session  bool   // --session：进入 /recall 多轮会话
+	AsOf     string // --as-of YYYY-MM-DD
+}
+
+func parseAskArgs(input string) askArgs {
+	var args askArgs
+	input, args.AsOf = splitAsOf(input)
 
 	var q []string
-	for _, p := range parts {
-		if p == "--refs" {
-			showRefs = true
-		} else {
+	for _, p := range strings.Fields(input) {
+		switch p {
+		case "--refs":
+			args.ShowRefs = true
+		case "--json":
+			args.JSON = true
+		case "--session":
+			args.Session = true
+		default:
 			q = append(q, p)
 		}
 	}
-	return strings.Join(q, " "), showRefs
+	args.Question = strings.Join(q, " ")
+	return args
 }
 
 /*
@@ -105,7 +299,10 @@ Prompt Builder
 ========================
 */
 
-func buildAskPrompt(memoryContext, question string) string {
+func buildAskPrompt(memoryContext, history, question string) string {
+	if history != "" {
+		history = "\n【本次会话中之前的问答】\n" + history + "\n"
+	}
 	return fmt.Sprintf(`
 你是“基于用户自身长期记忆”的智能助理，而不是百科或搜索引擎。
 
@@ -117,7 +314,7 @@ func buildAskPrompt(memoryContext, question string) string {
 
 【用户的历史记录】
 %s
-
+%s
 【用户当前的问题】
 %s
 
@@ -126,8 +323,14 @@ func buildAskPrompt(memoryContext, question string) string {
 如果记录中存在多个观点，请合并总结。
 如果信息不足，请直接说明“不足以回答”。
 
+【引用规则（必须遵守）】
+- 每一句基于记录的论断，句末都要标注来源编号，例如：你当时在调试 SQLite 锁问题[2]。
+- 一句话来自多段记录时写成 [1][3]
+- 只能使用上面出现过的编号，不要编造编号
+- 不要在回答末尾另列参考文献
+
 请开始回答：
-`, memoryContext, question)
+`, memoryContext, history, question)
 }
 
 /*
@@ -136,22 +339,13 @@ Reference Formatting
 ========================
 */
 
-func formatTopReference(h SearchHit) string {
-	return fmt.Sprintf(
-		"参考：你在 %s 的 %s 记录（%s）。",
-		h.Date,
-		h.Type,
-		firstLine(h.Text),
-	)
-}
-
 func formatRefLine(idx int, h SearchHit) string {
 	return fmt.Sprintf(
 		"%d. [%.2f] %s %s · %s",
 		idx,
 		h.Score,
 		h.Date,
-		h.Type,
+		hitLabel(h),
 		firstLine(h.Text),
 	)
 }
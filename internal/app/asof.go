@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+========================
+As-of (time travel)
+- --as-of YYYY-MM-DD：把“现在”模拟为过去某一天
+- 检索只使用在这一天之前结束的 summary；chat 使用这一天的 daily 与原始对话
+========================
+*/
+
+// parseAsOfDate：日期取 as-of 当天，时分秒沿用当前时间；不允许未来日期
+func parseAsOfDate(cfg Config, s string) (time.Time, error) {
+	d, err := time.ParseInLocation("2006-01-02", s, cfg.Location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --as-of date %q (want YYYY-MM-DD)", s)
+	}
+
+	now := time.Now().In(cfg.Location)
+	if d.After(now) {
+		return time.Time{}, fmt.Errorf("--as-of %s is in the future", s)
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), now.Hour(), now.Minute(), now.Second(), 0, cfg.Location), nil
+}
+
+// splitAsOf：从命令参数中取出 --as-of <date>，返回其余文本
+func splitAsOf(input string) (rest, asOf string) {
+	return splitFlag(input, "--as-of")
+}
+
+// splitFlag：取出带值的参数（例如 --save file.json），返回其余文本
+func splitFlag(input, flag string) (rest, value string) {
+	var out []string
+	fields := strings.Fields(input)
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == flag && i+1 < len(fields) {
+			value = fields[i+1]
+			i++
+			continue
+		}
+		out = append(out, fields[i])
+	}
+	return strings.Join(out, " "), value
+}
+
+// resolveAsOf：空字符串 = 不模拟（零值）
+func resolveAsOf(cfg Config, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return parseAsOfDate(cfg, s)
+}
+
+// clockAt：as-of 非零时以它为“现在”
+func clockAt(cfg Config, asOf time.Time) time.Time {
+	if asOf.IsZero() {
+		return time.Now().In(cfg.Location)
+	}
+	return asOf
+}
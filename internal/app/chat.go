@@ -14,11 +14,25 @@ import (
 )
 
 type SSEChunk struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Delta struct {
 			Content string `json:"content"`
 		} `json:"delta"`
 	} `json:"choices"`
+	Usage *TokenUsage `json:"usage"` // 仅最后一个 chunk（stream_options.include_usage）
+}
+
+// 生成参数（空 = 使用 llama-server 的默认值）；会随 assistant 记录写入日志
+var chatGenParams = map[string]any{}
+
+// chatCompletion：一次流式调用的结果与元数据（写入 LogRecord）
+type chatCompletion struct {
+	Content string
+	Model   string
+	Params  map[string]any
+	Latency time.Duration
+	Usage   *TokenUsage
 }
 
 // 本地默认超时：避免 CLI “卡死”
@@ -38,6 +52,15 @@ func streamChatWithContext(
 	contextMessages []map[string]string,
 	userQuestion string,
 ) string {
+	return streamChatMessages(chatMessages(systemPrompt, contextMessages, userQuestion))
+}
+
+// chatMessages：system → 历史消息 → 本次 user 输入
+func chatMessages(
+	systemPrompt string,
+	contextMessages []map[string]string,
+	userQuestion string,
+) []map[string]string {
 
 	messages := []map[string]string{}
 
@@ -59,29 +82,49 @@ func streamChatWithContext(
 		"content": userQuestion,
 	})
 
+	return messages
+}
+
+// streamChatMessages：发送已经组装好的 messages（/replay 直接重放抓取的数组）
+func streamChatMessages(messages []map[string]string) string {
+	return streamChatCompletion(messages).Content
+}
+
+// streamChatCompletion：同 streamChatMessages，同时返回模型 / 延迟 / token 用量
+func streamChatCompletion(messages []map[string]string) chatCompletion {
+	start := time.Now()
+	result := chatCompletion{Model: chatModel}
+	if len(chatGenParams) > 0 {
+		result.Params = chatGenParams
+	}
+
 	payload := map[string]any{
-		"model":    chatModel,
-		"stream":   true,
-		"messages": messages,
+		"model":          chatModel,
+		"stream":         true,
+		"stream_options": map[string]any{"include_usage": true},
+		"messages":       messages,
+	}
+	for k, v := range chatGenParams {
+		payload[k] = v
 	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		fmt.Println("marshal error:", err)
-		return ""
+		return result
 	}
 
 	req, err := http.NewRequest("POST", chatURL, bytes.NewReader(body))
 	if err != nil {
 		fmt.Println("new request error:", err)
-		return ""
+		return result
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := chatHTTPClient.Do(req)
 	if err != nil {
 		fmt.Println("request error:", err)
-		return ""
+		return result
 	}
 	defer resp.Body.Close()
 
@@ -89,7 +132,7 @@ func streamChatWithContext(
 	if resp.StatusCode/100 != 2 {
 		b, _ := io.ReadAll(resp.Body)
 		fmt.Printf("http error: %d\n%s\n", resp.StatusCode, strings.TrimSpace(string(b)))
-		return ""
+		return result
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
@@ -118,6 +161,12 @@ func streamChatWithContext(
 			// SSE 中偶尔有非 JSON 行，忽略即可
 			continue
 		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage
+		}
 		if len(chunk.Choices) == 0 {
 			continue
 		}
@@ -140,7 +189,9 @@ func streamChatWithContext(
 		fmt.Println("\nstream error:", err)
 	}
 
-	return full.String()
+	result.Content = full.String()
+	result.Latency = time.Since(start)
+	return result
 }
 
 // 根据文本里的 ``` 出现次数切换 code block 状态（出现奇数次就 toggle）
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 /*
@@ -14,8 +15,9 @@ PromptBlock 表示一段被注入到 LLM Prompt 中的上下文块。
 */
 type PromptBlock struct {
 	Role    string // system | user | assistant
-	Source  string // daily_summary | search_hit | recent_raw
+	Source  string // daily_summary | related_periods | open_questions | search_hit | session_summary | recent_raw
 	Content string
+	Summary string // 可选：超出预算时替换 Content 的精简版本（见 prompt_budget.go）
 }
 
 // 构建 chat 上下文（被 Chat / DebugChat 行为调用）
@@ -25,6 +27,7 @@ func BuildChatContext(
 	db *sql.DB,
 	date string,
 	userQuestion string, // 保留参数，仅用于 search
+	asOf time.Time, // 非零 = --as-of：长期记忆只用这一天之前结束的 summary
 ) []PromptBlock {
 
 	var ctx []PromptBlock
@@ -35,11 +38,30 @@ func BuildChatContext(
 			Role:    "assistant",
 			Source:  "daily_summary",
 			Content: "这是今天的对话摘要：\n" + daily,
+			Summary: "这是今天的对话摘要（精简）：\n" + extractHumanText(daily),
+		})
+	}
+
+	// 1️⃣·b 与今天相似的过往时期（daily 生成时写入的 related 链接）
+	if related := formatRelatedBlock(loadRelatedLinks(cfg, date)); related != "" {
+		ctx = append(ctx, PromptBlock{
+			Role:    "assistant",
+			Source:  "related_periods",
+			Content: related,
+		})
+	}
+
+	// 1️⃣·c 与当前消息相关的长期未解决问题（最老的几条）
+	if open := formatOpenQuestionBlock(relatedOpenQuestions(cfg, db, date, userQuestion, cfg.ChatOpenQuestions)); open != "" {
+		ctx = append(ctx, PromptBlock{
+			Role:    "assistant",
+			Source:  "open_questions",
+			Content: open,
 		})
 	}
 
 	// 2️⃣ 相似历史（长期记忆：embedding 命中，排除今天）
-	hits, err := SearchWithScore(db, cfg, userQuestion)
+	hits, err := RetrieveMemories(db, cfg, userQuestion, SearchOptions{AsOf: asOf})
 	if err == nil && len(hits) > 0 {
 		var b strings.Builder
 		b.WriteString("这是你过去相关的问题和记录：\n")
@@ -67,14 +89,28 @@ func BuildChatContext(
 		}
 	}
 
+	// 2️⃣·b 长会话的“会话至今”摘要（滚动压缩，见 session_compact.go）
+	if asOf.IsZero() {
+		if summary := sessionSummaryBlock(db); summary != "" {
+			ctx = append(ctx, PromptBlock{
+				Role:    "assistant",
+				Source:  "session_summary",
+				Content: summary,
+			})
+		}
+	}
+
 	// 3️⃣ 最近 raw 对话（短期工作上下文）
-	// ⚠️ 只保留 user，彻底阻断 assistant 风格回流
-	if recent := loadRecentRaw(cfg, date, 20); recent != "" {
-		ctx = append(ctx, PromptBlock{
-			Role:    "assistant",
-			Source:  "recent_raw",
-			Content: "以下是最近的原始对话记录：\n" + recent,
-		})
+	// 默认以 user / assistant 消息发送（见 chat_history.go）；ChatHistoryMode=system 时沿用旧的 system 块
+	// ⚠️ 旧块只保留 user，彻底阻断 assistant 风格回流
+	if cfg.ChatHistoryMode == chatHistorySystem {
+		if recent := loadRecentRaw(cfg, date, 20); recent != "" {
+			ctx = append(ctx, PromptBlock{
+				Role:    "assistant",
+				Source:  "recent_raw",
+				Content: "以下是最近的原始对话记录：\n" + recent,
+			})
+		}
 	}
 
 	// ❌ 重要：不再注入当前 userQuestion
@@ -92,15 +128,18 @@ func loadDailySummary(cfg Config, date string) string {
 	if err != nil {
 		return ""
 	}
-	return strings.TrimSpace(string(b))
+	// related 单独作为 related_periods 块注入
+	return strings.TrimSpace(stripRelated(string(b)))
 }
 
-// 读取最近 raw 对话（干净版）
+// 读取最近 raw 对话（干净版）；--as-of 回到较早日期时，原始日志可能已在归档中
 func loadRecentRaw(cfg Config, date string, maxLines int) string {
 	path := filepath.Join(cfg.LogDir, date+".jsonl")
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return ""
+		if b, err = readArchivedDay(cfg, date); err != nil {
+			return ""
+		}
 	}
 
 	lines := strings.Split(string(b), "\n")
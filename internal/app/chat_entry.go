@@ -2,33 +2,73 @@ package app
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
 	"time"
 )
 
 // Chat = 对话行为入口（唯一！）
 func Chat(lw *LogWriter, cfg Config, db *sql.DB, input string) error {
-	// === 0️⃣ 系统时间（权威事实来源） ===
-	now := time.Now().In(cfg.Location)
+	return chatTurn(lw, cfg, db, input, time.Time{}, recordKindChat)
+}
+
+// ChatAsOf：asOf 非零时模拟“过去某一天”的对话
+// - 上下文使用那一天的 daily 与原始对话，长期记忆只用之前结束的 summary
+// - 模拟对话不写入 raw log（避免把假设的对话变成新的记忆）
+func ChatAsOf(lw *LogWriter, cfg Config, db *sql.DB, input string, asOf time.Time) error {
+	return chatTurn(lw, cfg, db, input, asOf, recordKindChat)
+}
+
+// chatTurn：kind 记录输入来源（chat / paste）
+func chatTurn(lw *LogWriter, cfg Config, db *sql.DB, input string, asOf time.Time, kind string) error {
+	simulated := !asOf.IsZero()
+
+	// === 0️⃣ 长会话滚动压缩（失败不影响本次对话）===
+	if !simulated {
+		date := clockAt(cfg, asOf).Format("2006-01-02")
+		if err := compactSession(lw, cfg, db, date); err != nil {
+			fmt.Println("[warn] session compaction failed:", err)
+		}
+	}
 
-	// === 1️⃣ 写 user raw ===
-	_ = lw.WriteRecord(map[string]string{
-		"role":    "user",
-		"content": input,
-	})
+	// === 1️⃣ 组装 prompt（系统事实 / 上下文块 / 历史，见 prompt_builder.go）===
+	// 注意：必须在写入本次 user 输入之前组装，否则历史里会重复一次
+	prompt := NewPromptBuilder(cfg, db).Build(input, asOf)
 
-	// === 2️⃣ 构建上下文（历史 / 事实） ===
-	// 注意：这里的 BuildChatContext 里不要再注入 user input（否则会重复一次）
-	date := now.Format("2006-01-02")
-	blocks := BuildChatContext(cfg, db, date, input)
+	// === 2️⃣ 写 user raw ===
+	if !simulated {
+		_ = lw.WriteRecord(LogRecord{Role: "user", Kind: kind, Content: input})
+	}
+
+	// === 3️⃣ 调用流式 chat ===
+	result := streamChatCompletion(prompt.Messages)
+
+	// === 4️⃣ 写 assistant raw（带模型 / 延迟 / token 用量）===
+	if !simulated {
+		_ = lw.WriteRecord(assistantRecord(kind, result))
+	}
 
-	// === 3️⃣ 构建 system prompt ===
+	return nil
+}
+
+// plainChatTurn：不带长期记忆的即时回答（DefaultUseLongTermChat = false）
+func plainChatTurn(lw *LogWriter, input, kind string) {
+	result := streamChatCompletion(chatMessages("", nil, input))
+	_ = lw.WriteRecord(LogRecord{Role: "user", Kind: kind, Content: input})
+	_ = lw.WriteRecord(assistantRecord(kind, result))
+}
+
+// systemFacts：system prompt 开头的“系统事实”块（Chat 与 /debug 共用）
+// simulated = --as-of：日期为模拟日期，并明确告诉模型以当时的视角回答
+func systemFacts(now time.Time, simulated bool) string {
 	var system strings.Builder
 
-	// --- 系统事实（时间）---
 	system.WriteString("【系统事实（权威）】\n")
 	system.WriteString("当前日期：")
 	system.WriteString(now.Format("2006-01-02"))
+	if simulated {
+		system.WriteString("（模拟日期：用户在回看这一天，之后发生的事你并不知道）")
+	}
 	system.WriteString("\n")
 
 	system.WriteString("当前时间：")
@@ -48,26 +88,5 @@ func Chat(lw *LogWriter, cfg Config, db *sql.DB, input string) error {
 			"涉及日期、时间、星期的问题，请直接基于这些事实回答，不允许猜测或自行推断。\n\n",
 	)
 
-	// --- 原有 system 说明 ---
-	system.WriteString("以下是用户的对话历史与已知事实，请严格基于这些信息回答。\n\n")
-
-	for _, b := range blocks {
-		system.WriteString(b.Content)
-		system.WriteString("\n\n")
-	}
-
-	// === 4️⃣ 调用流式 chat ===
-	answer := streamChatWithContext(
-		system.String(),
-		nil,
-		input,
-	)
-
-	// === 5️⃣ 写 assistant raw ===
-	_ = lw.WriteRecord(map[string]string{
-		"role":    "assistant",
-		"content": answer,
-	})
-
-	return nil
+	return system.String()
 }
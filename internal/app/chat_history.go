@@ -0,0 +1,87 @@
+package app
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+/*
+========================
+Chat History (multi-turn)
+- messages（默认）：当前会话最近几轮对话作为真正的 user / assistant 消息发送，模型能看到自己之前的回答
+- system：旧行为，只把最近的 user 行拼进 system prompt 的 recent_raw 块
+========================
+*/
+
+const (
+	chatHistoryMessages = "messages"
+	chatHistorySystem   = "system"
+)
+
+// loadChatHistory：dates 这几天原始日志中属于 session、轮次 > after 的最近 turns 轮（user → assistant 交替，以 assistant 结尾）
+// session 为空 = 不按会话过滤；必须在写入当前 user 输入之前调用，否则当前输入会重复出现
+func loadChatHistory(cfg Config, dates []string, session string, after, turns int) []map[string]string {
+	if turns <= 0 {
+		return nil
+	}
+
+	var msgs []map[string]string
+	for _, date := range dates {
+		lines, _, err := loadRawTranscript(cfg, date)
+		if err != nil {
+			continue
+		}
+		for _, l := range lines {
+			// /ask、/recall 的问答不是对话本身，不作为历史发回模型
+			if (l.Role != "user" && l.Role != "assistant") || l.Kind == recordKindAsk {
+				continue
+			}
+			if session != "" && (l.Session != session || l.Turn <= after) {
+				continue
+			}
+			content := strings.TrimSpace(l.Content)
+			if content == "" {
+				continue
+			}
+			// 连续同角色（例如模型请求失败后用户重试）合并为一条，保证严格交替
+			if n := len(msgs); n > 0 && msgs[n-1]["role"] == l.Role {
+				msgs[n-1]["content"] += "\n\n" + content
+				continue
+			}
+			msgs = append(msgs, map[string]string{"role": l.Role, "content": content})
+		}
+	}
+
+	// 末尾没有回答的 user 消息丢弃（当前输入会作为最后一条 user 消息发送）
+	if n := len(msgs); n > 0 && msgs[n-1]["role"] == "user" {
+		msgs = msgs[:n-1]
+	}
+	if len(msgs) > turns*2 {
+		msgs = msgs[len(msgs)-turns*2:]
+	}
+	// 必须以 user 开头
+	for len(msgs) > 0 && msgs[0]["role"] != "user" {
+		msgs = msgs[1:]
+	}
+	return msgs
+}
+
+// chatHistoryFor：按配置决定是否以消息形式发送历史
+// - 正常对话：当前会话的最近几轮（会话跨午夜时包含前一天；已压缩进会话摘要的轮次不再发送）
+// - --as-of 模拟：那一天的全部对话，不按会话过滤
+func chatHistoryFor(cfg Config, db *sql.DB, date string, simulated bool) []map[string]string {
+	if cfg.ChatHistoryMode == chatHistorySystem {
+		return nil
+	}
+	if simulated {
+		return loadChatHistory(cfg, []string{date}, "", 0, cfg.ChatHistoryTurns)
+	}
+
+	s, err := currentSession(db)
+	if err != nil {
+		return loadChatHistory(cfg, []string{date}, "", 0, cfg.ChatHistoryTurns)
+	}
+	dates := sessionHistoryDates(s, date, cfg.Location, 2)
+	return loadChatHistory(cfg, dates, strconv.FormatInt(s.ID, 10), s.SummaryTurn, cfg.ChatHistoryTurns)
+}
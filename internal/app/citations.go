@@ -0,0 +1,173 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+========================
+Citations (/ask grounding)
+- Ask 给每段记忆编号 [n]，要求模型在句末标注引用
+- 生成后解析并校验引用：只列出被引用的记忆；越界引用、未引用的论断都会被标出
+========================
+*/
+
+var citationRe = regexp.MustCompile(`\[(\d{1,3})(?:\s*[,，]\s*\d{1,3})*\]`)
+var citationNumRe = regexp.MustCompile(`\d{1,3}`)
+
+// 太短的片段（“好的。”“总结：”）不算需要引用的论断
+const minClaimRunes = 8
+
+type CitationCheck struct {
+	Cited    []int    // 合法引用（升序，去重）
+	Invalid  []int    // 超出记忆编号范围的引用
+	Uncited  []string // 没有任何引用的论断
+	Grounded bool     // 至少有一个合法引用
+}
+
+// checkCitations：n 为提供给模型的记忆数量（编号 1..n）
+func checkCitations(answer string, n int) CitationCheck {
+	var c CitationCheck
+
+	cited := make(map[int]bool)
+	invalid := make(map[int]bool)
+
+	for _, m := range citationRe.FindAllString(answer, -1) {
+		for _, d := range citationNumRe.FindAllString(m, -1) {
+			i, _ := strconv.Atoi(d)
+			if i >= 1 && i <= n {
+				cited[i] = true
+			} else {
+				invalid[i] = true
+			}
+		}
+	}
+
+	for _, claim := range splitClaims(answer) {
+		if !citationRe.MatchString(claim) {
+			c.Uncited = append(c.Uncited, claim)
+		}
+	}
+
+	c.Cited = sortedKeys(cited)
+	c.Invalid = sortedKeys(invalid)
+	c.Grounded = len(c.Cited) > 0
+	return c
+}
+
+// splitClaims：按句末标点 / 换行切分为论断；引用标记跟在标点后也归入前一句
+func splitClaims(text string) []string {
+	var (
+		out []string
+		cur strings.Builder
+	)
+
+	flush := func() {
+		s := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if s == "" {
+			return
+		}
+		// “[1]” 单独成段时并回上一句
+		if citationRe.ReplaceAllString(s, "") == "" && len(out) > 0 {
+			out[len(out)-1] += s
+			return
+		}
+		if runeLen(citationRe.ReplaceAllString(s, "")) >= minClaimRunes {
+			out = append(out, s)
+		}
+	}
+
+	rs := []rune(text)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if r == '\n' {
+			flush()
+			continue
+		}
+		cur.WriteRune(r)
+
+		switch r {
+		case '。', '！', '？', '!', '?', '；':
+		case '.':
+			// 避免切开 3.5 / e.g. 这类
+			if i+1 < len(rs) && rs[i+1] != ' ' && rs[i+1] != '\n' && rs[i+1] != '[' {
+				continue
+			}
+		default:
+			continue
+		}
+
+		// 句末标点后紧跟的引用标记属于这一句
+		j := i + 1
+		for j < len(rs) && rs[j] == ' ' {
+			j++
+		}
+		if j < len(rs) && rs[j] == '[' {
+			if loc := citationRe.FindStringIndex(string(rs[j:])); loc != nil && loc[0] == 0 {
+				tail := []rune(string(rs[j:])[:loc[1]])
+				cur.WriteString(string(tail))
+				i = j + len(tail) - 1
+			}
+		}
+		flush()
+	}
+	flush()
+
+	return out
+}
+
+func sortedKeys(m map[int]bool) []int {
+	out := make([]int, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// stripCitations：朗读 / 纯文本输出时去掉 [n]
+func stripCitations(s string) string {
+	return citationRe.ReplaceAllString(s, "")
+}
+
+// formatCitationFooter：只列出被引用的记忆，并标出越界引用与未引用的论断
+func formatCitationFooter(hits []SearchHit, c CitationCheck) string {
+	var b strings.Builder
+	b.WriteString("\n\n——\n")
+
+	if !c.Grounded {
+		b.WriteString("⚠️ 这个回答没有引用任何记忆，不是基于你的历史记录得出的，请谨慎对待。\n")
+	} else {
+		b.WriteString("引用：\n")
+		for _, n := range c.Cited {
+			h := hits[n-1]
+			if h.Type == "raw" {
+				b.WriteString(fmt.Sprintf("[%d] 你在 %s 的原始对话 %s %s（%s）\n", n, h.Date, h.Doc, lineRange(h), firstLine(h.Text)))
+				continue
+			}
+			b.WriteString(fmt.Sprintf("[%d] 你在 %s 的 %s 记录（%s）\n", n, h.Date, h.Type, firstLine(h.Text)))
+		}
+	}
+
+	if len(c.Invalid) > 0 {
+		var xs []string
+		for _, n := range c.Invalid {
+			xs = append(xs, fmt.Sprintf("[%d]", n))
+		}
+		b.WriteString("⚠️ 无效引用（不存在的记忆编号）：" + strings.Join(xs, " ") + "\n")
+	}
+
+	if c.Grounded && len(c.Uncited) > 0 {
+		b.WriteString("⚠️ 以下论断没有标注引用，可能不是来自你的记录：\n")
+		for _, u := range c.Uncited {
+			b.WriteString("  - " + u + "\n")
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
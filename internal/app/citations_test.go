@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckCitations(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   string
+		n        int
+		cited    []int
+		invalid  []int
+		uncited  []string
+		grounded bool
+	}{
+		{
+			name:     "adjacent markers",
+			answer:   "你在调试 SQLite 锁问题[1][3]。后来改用了 WAL 模式[2]。",
+			n:        3,
+			cited:    []int{1, 2, 3},
+			grounded: true,
+		},
+		{
+			name:     "comma separated marker",
+			answer:   "两次复盘都提到了预算问题[1, 2]。",
+			n:        2,
+			cited:    []int{1, 2},
+			grounded: true,
+		},
+		{
+			name:     "out of range numbers",
+			answer:   "你当时在重构检索模块[4]。也考虑过换数据库[0]。",
+			n:        3,
+			invalid:  []int{0, 4},
+			grounded: false,
+		},
+		{
+			name:     "valid and invalid mixed",
+			answer:   "你在调试锁问题[1][7]。",
+			n:        2,
+			cited:    []int{1},
+			invalid:  []int{7},
+			grounded: true,
+		},
+		{
+			name:     "uncited claim",
+			answer:   "你在调试锁问题[1]。这个结论没有任何来源支持。",
+			n:        1,
+			cited:    []int{1},
+			uncited:  []string{"这个结论没有任何来源支持。"},
+			grounded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := checkCitations(tt.answer, tt.n)
+			// nil 与空切片等价
+			if fmt.Sprint(c.Cited) != fmt.Sprint(tt.cited) {
+				t.Errorf("cited = %v, want %v", c.Cited, tt.cited)
+			}
+			if fmt.Sprint(c.Invalid) != fmt.Sprint(tt.invalid) {
+				t.Errorf("invalid = %v, want %v", c.Invalid, tt.invalid)
+			}
+			if fmt.Sprintf("%q", c.Uncited) != fmt.Sprintf("%q", tt.uncited) {
+				t.Errorf("uncited = %q, want %q", c.Uncited, tt.uncited)
+			}
+			if c.Grounded != tt.grounded {
+				t.Errorf("grounded = %v, want %v", c.Grounded, tt.grounded)
+			}
+		})
+	}
+}
+
+func TestSplitClaims(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "cjk sentence breaks",
+			text: "第一句话说得比较长。第二句话也比较长！第三句话是个问题吗？第四句用分号结束；",
+			want: []string{"第一句话说得比较长。", "第二句话也比较长！", "第三句话是个问题吗？", "第四句用分号结束；"},
+		},
+		{
+			name: "marker after punctuation belongs to the sentence",
+			text: "你在调试锁问题。[1] 后来又换了方案。[2]",
+			want: []string{"你在调试锁问题。[1]", "后来又换了方案。[2]"},
+		},
+		{
+			name: "decimal point does not split",
+			text: "版本从 3.5 升级到了 4.0 之后更稳定了。",
+			want: []string{"版本从 3.5 升级到了 4.0 之后更稳定了。"},
+		},
+		{
+			name: "short fragments are dropped",
+			text: "好的。\n总结：\n这是一个足够长的论断[1]。",
+			want: []string{"这是一个足够长的论断[1]。"},
+		},
+		{
+			name: "marker on its own line joins the previous claim",
+			text: "这是一个足够长的论断\n[2]",
+			want: []string{"这是一个足够长的论断[2]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitClaims(tt.text)
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("splitClaims = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Commitments (/goals)
+- weekly 的 next_week_focus / monthly 的 next_month_bets 写入 commitments 表，目标期 = 下一周 / 下一月
+- 目标期的 summary 生成后，由模型结合该期 summary 判定每一条：achieved / partial / dropped / carried_over
+- /goals 汇总各期的命中率
+========================
+*/
+
+const (
+	commitmentPending  = "pending"
+	commitmentAchieved = "achieved"
+	commitmentPartial  = "partial"
+	commitmentDropped  = "dropped"
+	commitmentCarried  = "carried_over"
+)
+
+// 每种 summary 中“对下一期的承诺”字段
+var commitmentFields = map[string]string{
+	"weekly":  "next_week_focus",
+	"monthly": "next_month_bets",
+}
+
+// 判定时提供给模型的该期字段（证据来源）
+var commitmentEvidenceFields = map[string][]string{
+	"weekly":  {"themes", "progress", "notable_decisions", "recurring_blockers"},
+	"monthly": {"trajectory", "top_themes", "wins", "losses", "systems_improvements"},
+}
+
+type Commitment struct {
+	ID         int64
+	SourceType string
+	SourceKey  string // 提出承诺的时期
+	TargetKey  string // 需要兑现的时期
+	Text       string
+	Status     string
+	Evidence   string
+}
+
+// trackCommitments：summary 生成后调用（force 重新生成时会重新判定、重新提取）
+func trackCommitments(cfg Config, db *sql.DB, typ, key, summaryJSON string) error {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(summaryJSON), &obj); err != nil {
+		return err
+	}
+
+	// 判定失败（模型不可用等）不影响记录本期的承诺；未判定的条目下次重新生成时再判
+	judgeErr := judgeCommitments(cfg, db, typ, key, obj)
+	if err := recordCommitments(db, typ, key, obj); err != nil {
+		return err
+	}
+	return judgeErr
+}
+
+/*
+========================
+Extraction
+========================
+*/
+
+func recordCommitments(db *sql.DB, typ, key string, obj map[string]any) error {
+	target, err := nextPeriodKey(typ, key)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 重新生成时：未判定的旧条目以新 summary 为准
+	if _, err := tx.Exec(`DELETE FROM commitments WHERE source_type=? AND source_key=? AND status=?`,
+		typ, key, commitmentPending); err != nil {
+		return err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if list, ok := obj[commitmentFields[typ]].([]any); ok {
+		for _, it := range list {
+			text := strings.TrimSpace(itemText(it))
+			if runeLen(text) < 2 {
+				continue
+			}
+			if _, err := tx.Exec(`
+				INSERT OR IGNORE INTO commitments(source_type, source_key, target_key, text, created_at)
+				VALUES(?, ?, ?, ?, ?)
+			`, typ, key, target, text, now); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// nextPeriodKey：2025-W52 → 2026-W01，2025-12 → 2026-01
+func nextPeriodKey(typ, key string) (string, error) {
+	switch typ {
+	case "weekly":
+		year, week := parseWeekKey(key)
+		if year == 0 || week == 0 {
+			return "", fmt.Errorf("invalid week key %q", key)
+		}
+		// ISO 第 1 周必含 1 月 4 日
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+		y, w := monday.AddDate(0, 0, 7).ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", y, w), nil
+	case "monthly":
+		t, err := time.Parse("2006-01", key)
+		if err != nil {
+			return "", err
+		}
+		return t.AddDate(0, 1, 0).Format("2006-01"), nil
+	}
+	return "", fmt.Errorf("no commitments for %s summaries", typ)
+}
+
+/*
+========================
+Judging
+========================
+*/
+
+type commitmentVerdict struct {
+	ID       int64  `json:"id"`
+	Status   string `json:"status"`
+	Evidence string `json:"evidence"`
+}
+
+// judgeCommitments：目标期为 key 的承诺，由模型根据本期 summary 判定
+func judgeCommitments(cfg Config, db *sql.DB, typ, key string, obj map[string]any) error {
+	items, err := loadCommitments(db, `WHERE source_type=? AND target_key=? ORDER BY id`, typ, key)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	// 本期自己的承诺字段也一并提供：仍在其中 = carried_over，否则才可能是 dropped
+	evidence := make(map[string]any)
+	for _, f := range append([]string{commitmentFields[typ]}, commitmentEvidenceFields[typ]...) {
+		if v, ok := obj[f]; ok {
+			evidence[f] = v
+		}
+	}
+	evJSON, _ := json.MarshalIndent(evidence, "", "  ")
+
+	out, err := callLLMNonStream(buildCommitmentJudgePrompt(typ, key, items, string(evJSON)))
+	if err != nil {
+		return err
+	}
+
+	var verdicts []commitmentVerdict
+	if err := json.Unmarshal([]byte(extractJSONArray(out)), &verdicts); err != nil {
+		return fmt.Errorf("commitment verdicts invalid JSON: %w\nraw:\n%s", err, out)
+	}
+
+	valid := make(map[int64]bool, len(items))
+	for _, c := range items {
+		valid[c.ID] = true
+	}
+
+	today := time.Now().In(cfg.Location).Format("2006-01-02")
+	for _, v := range verdicts {
+		if !valid[v.ID] || !isCommitmentStatus(v.Status) {
+			continue
+		}
+		if _, err := db.Exec(`UPDATE commitments SET status=?, evidence=?, judged_at=? WHERE id=?`,
+			v.Status, strings.TrimSpace(v.Evidence), today, v.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isCommitmentStatus(s string) bool {
+	switch s {
+	case commitmentAchieved, commitmentPartial, commitmentDropped, commitmentCarried:
+		return true
+	}
+	return false
+}
+
+// extractJSONArray：去掉模型可能附带的说明 / 代码围栏，只保留最外层 [...]
+func extractJSONArray(s string) string {
+	i := strings.Index(s, "[")
+	j := strings.LastIndex(s, "]")
+	if i < 0 || j < i {
+		return s
+	}
+	return s[i : j+1]
+}
+
+func buildCommitmentJudgePrompt(typ, key string, items []Commitment, evidence string) string {
+	period := "这一周"
+	if typ == "monthly" {
+		period = "这个月"
+	}
+
+	var list strings.Builder
+	for _, c := range items {
+		list.WriteString(fmt.Sprintf("- id %d：%s（来自 %s）\n", c.ID, c.Text, c.SourceKey))
+	}
+
+	return fmt.Sprintf(`
+你在帮用户复盘：上一期定下的目标，在%s（%s）有没有兑现。
+（总结中的 %s 是本期为下一期定下的计划）
+
+【上一期定下的目标】
+%s
+【%s的总结（唯一证据来源）】
+%s
+
+【判定标准】
+- achieved：总结中有明确证据表明已完成
+- partial：有进展但没有完成
+- dropped：没有任何相关进展，或总结表明已经放弃
+- carried_over：没有完成，但仍是本期的重点（例如又出现在 %s 或主题中）
+
+【要求】
+- 只能依据上面的总结判断，不要猜测
+- evidence 用一句话引用或概括总结中的相关内容；没有证据时写“总结中没有提到”
+- 每个 id 输出一条，只输出 JSON 数组，不要解释：
+[{"id": 1, "status": "achieved", "evidence": "..."}]
+`, period, key, commitmentFields[typ], list.String(), period, evidence, commitmentFields[typ])
+}
+
+/*
+========================
+Report (/goals)
+========================
+*/
+
+func loadCommitments(db *sql.DB, where string, args ...any) ([]Commitment, error) {
+	rows, err := db.Query(`
+		SELECT id, source_type, source_key, target_key, text, status, evidence
+		FROM commitments `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Commitment
+	for rows.Next() {
+		var c Commitment
+		if err := rows.Scan(&c.ID, &c.SourceType, &c.SourceKey, &c.TargetKey, &c.Text, &c.Status, &c.Evidence); err != nil {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GoalsReport：/goals [weekly|monthly] [--detail]
+// 命中率 = (achieved + 0.5 × partial) / 已判定条数
+func GoalsReport(db *sql.DB, typ string, detail bool) (string, error) {
+	types := []string{"weekly", "monthly"}
+	if typ != "" {
+		if _, ok := commitmentFields[typ]; !ok {
+			return "", fmt.Errorf("usage: /goals [weekly|monthly] [--detail]")
+		}
+		types = []string{typ}
+	}
+
+	var b strings.Builder
+	for _, t := range types {
+		items, err := loadCommitments(db, `WHERE source_type=? ORDER BY target_key, id`, t)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(fmt.Sprintf("== %s（%s）==\n", t, commitmentFields[t]))
+		if len(items) == 0 {
+			b.WriteString("no commitments yet\n\n")
+			continue
+		}
+
+		var (
+			periods  []string
+			byTarget = make(map[string][]Commitment)
+			total    goalTally
+		)
+		for _, c := range items {
+			if _, ok := byTarget[c.TargetKey]; !ok {
+				periods = append(periods, c.TargetKey)
+			}
+			byTarget[c.TargetKey] = append(byTarget[c.TargetKey], c)
+		}
+
+		for _, p := range periods {
+			var tally goalTally
+			for _, c := range byTarget[p] {
+				tally.add(c.Status)
+				total.add(c.Status)
+			}
+			b.WriteString(fmt.Sprintf("%-9s %s\n", p, tally))
+			if detail {
+				for _, c := range byTarget[p] {
+					b.WriteString(fmt.Sprintf("    %s %s", commitmentMark(c.Status), c.Text))
+					if c.Evidence != "" {
+						b.WriteString("（" + c.Evidence + "）")
+					}
+					b.WriteString("\n")
+				}
+			}
+		}
+		b.WriteString(fmt.Sprintf("overall   %s\n\n", total))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+type goalTally struct {
+	achieved, partial, dropped, carried, pending int
+}
+
+func (t *goalTally) add(status string) {
+	switch status {
+	case commitmentAchieved:
+		t.achieved++
+	case commitmentPartial:
+		t.partial++
+	case commitmentDropped:
+		t.dropped++
+	case commitmentCarried:
+		t.carried++
+	default:
+		t.pending++
+	}
+}
+
+func (t goalTally) String() string {
+	judged := t.achieved + t.partial + t.dropped + t.carried
+	s := fmt.Sprintf("✅ %d  ◐ %d  ✗ %d  → %d", t.achieved, t.partial, t.dropped, t.carried)
+	if judged > 0 {
+		rate := (float64(t.achieved) + 0.5*float64(t.partial)) / float64(judged)
+		s = fmt.Sprintf("hit rate %3.0f%%  ", rate*100) + s
+	} else {
+		s = "hit rate    -  " + s
+	}
+	if t.pending > 0 {
+		s += fmt.Sprintf("  (%d pending)", t.pending)
+	}
+	return s
+}
+
+func commitmentMark(status string) string {
+	switch status {
+	case commitmentAchieved:
+		return "✅"
+	case commitmentPartial:
+		return "◐"
+	case commitmentDropped:
+		return "✗"
+	case commitmentCarried:
+		return "→"
+	}
+	return "·"
+}
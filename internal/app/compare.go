@@ -0,0 +1,224 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+========================
+Period Comparison (/compare)
+- 两个任意粒度的 summary（2025-11 vs 2025-12，2025-W10 vs 2025-W20）
+- 主题条目先做字面匹配，再用条目向量匹配：新增 / 消失 / 延续
+- LLM 基于两份 summary 写对比，引用 [1] [2]
+========================
+*/
+
+// 参与对比的“主题类”字段（按 summary 类型）
+var compareThemeFields = map[string][]string{
+	"daily":   {"topics", "patterns"},
+	"weekly":  {"themes", "recurring_blockers"},
+	"monthly": {"top_themes", "trajectory"},
+}
+
+type compareTheme struct {
+	Text string
+	vec  []float32
+}
+
+type ThemeDiff struct {
+	Added     []string
+	Dropped   []string
+	Persisted [][2]string // A 中的表述, B 中的表述
+}
+
+// Compare：/compare <periodA> <periodB>
+func Compare(db *sql.DB, cfg Config, keyA, keyB string) (string, error) {
+	a, err := loadComparePeriod(db, keyA)
+	if err != nil {
+		return "", err
+	}
+	b, err := loadComparePeriod(db, keyB)
+	if err != nil {
+		return "", err
+	}
+
+	model := activeEmbedModel(db, cfg)
+	themesA := loadCompareThemes(db, model, a)
+	themesB := loadCompareThemes(db, model, b)
+	diff := diffThemes(themesA, themesB, cfg.CompareLexicalScore, cfg.CompareThemeScore)
+
+	fmt.Println(formatThemeDiff(a, b, diff))
+	fmt.Println()
+
+	hits := []SearchHit{a, b}
+	_, check, err := streamAnswer(buildComparePrompt(a, b, diff), len(hits))
+	if err != nil {
+		return "", err
+	}
+	return formatCitationFooter(hits, check), nil
+}
+
+// inferPeriodType：根据 key 的格式判断 summary 类型
+func inferPeriodType(key string) (string, error) {
+	for _, typ := range []string{"daily", "weekly", "monthly"} {
+		if periodKeyPatterns[typ].MatchString(key) {
+			return typ, nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized period key %q (YYYY-MM-DD | YYYY-Www | YYYY-MM)", key)
+}
+
+func loadComparePeriod(db *sql.DB, key string) (SearchHit, error) {
+	typ, err := inferPeriodType(key)
+	if err != nil {
+		return SearchHit{}, err
+	}
+
+	h := SearchHit{Type: typ, Date: key}
+	var js string
+	err = db.QueryRow(`SELECT id, start_date, end_date, json FROM summaries WHERE type=? AND period_key=?`, typ, key).
+		Scan(&h.ID, &h.StartDate, &h.EndDate, &js)
+	if err != nil {
+		return SearchHit{}, fmt.Errorf("no %s summary for %s", typ, key)
+	}
+	h.Doc = js
+	h.Text = extractHumanText(js)
+	return h, nil
+}
+
+// loadCompareThemes：主题条目 + 已有的条目向量（没有向量的条目只参与字面匹配）
+func loadCompareThemes(db *sql.DB, model string, h SearchHit) []compareTheme {
+	fields := make(map[string]bool)
+	for _, f := range compareThemeFields[h.Type] {
+		fields[f] = true
+	}
+
+	vecs := make(map[string][]float32)
+	rows, err := db.Query(`SELECT field, item, vec, dim FROM embeddings WHERE summary_id=? AND model=? AND field <> ''`, h.ID, model)
+	if err == nil {
+		for rows.Next() {
+			var (
+				field, item string
+				blob        []byte
+				dim         int
+			)
+			if rows.Scan(&field, &item, &blob, &dim) != nil || !fields[field] {
+				continue
+			}
+			if v, ok := decodeVec(blob, dim); ok {
+				vecs[field+"\x00"+item] = v
+			}
+		}
+		rows.Close()
+	}
+
+	var out []compareTheme
+	for _, it := range extractIndexItems(h.Doc) {
+		if !fields[it.Field] {
+			continue
+		}
+		out = append(out, compareTheme{Text: it.Text, vec: vecs[it.Field+"\x00"+it.Text]})
+	}
+	return out
+}
+
+// diffThemes：字面相似度 ≥ lexical 或向量余弦 ≥ semantic 视为同一主题（贪心一对一匹配）
+func diffThemes(a, b []compareTheme, lexical, semantic float64) ThemeDiff {
+	var d ThemeDiff
+	used := make([]bool, len(b))
+
+	for _, x := range a {
+		best, bestScore := -1, 0.0
+		for j, y := range b {
+			if used[j] {
+				continue
+			}
+			score := 0.0
+			if s := textSimilarity(x.Text, y.Text); s >= lexical {
+				score = 1 + s // 字面命中优先
+			} else if x.vec != nil && y.vec != nil {
+				if c := cosineF32(x.vec, y.vec); c >= semantic {
+					score = c
+				}
+			}
+			if score > bestScore {
+				best, bestScore = j, score
+			}
+		}
+		if best < 0 {
+			d.Dropped = append(d.Dropped, x.Text)
+			continue
+		}
+		used[best] = true
+		d.Persisted = append(d.Persisted, [2]string{x.Text, b[best].Text})
+	}
+
+	for j, y := range b {
+		if !used[j] {
+			d.Added = append(d.Added, y.Text)
+		}
+	}
+	return d
+}
+
+func formatThemeDiff(a, b SearchHit, d ThemeDiff) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⚖️ %s %s（%s ~ %s）vs %s %s（%s ~ %s）\n",
+		a.Date, a.Type, a.StartDate, a.EndDate, b.Date, b.Type, b.StartDate, b.EndDate))
+
+	section := func(title string, items []string) {
+		sb.WriteString(fmt.Sprintf("\n%s（%d）\n", title, len(items)))
+		for _, it := range items {
+			sb.WriteString("  - " + it + "\n")
+		}
+	}
+	section("＋ 新增", d.Added)
+	section("－ 消失", d.Dropped)
+
+	sb.WriteString(fmt.Sprintf("\n＝ 延续（%d）\n", len(d.Persisted)))
+	for _, p := range d.Persisted {
+		if p[0] == p[1] {
+			sb.WriteString("  - " + p[0] + "\n")
+		} else {
+			sb.WriteString("  - " + p[0] + " → " + p[1] + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func buildComparePrompt(a, b SearchHit, d ThemeDiff) string {
+	var diff strings.Builder
+	for _, x := range d.Added {
+		diff.WriteString("- 新增：" + x + "\n")
+	}
+	for _, x := range d.Dropped {
+		diff.WriteString("- 消失：" + x + "\n")
+	}
+	for _, p := range d.Persisted {
+		diff.WriteString("- 延续：" + p[0] + " → " + p[1] + "\n")
+	}
+
+	return fmt.Sprintf(`
+你在帮用户做阶段复盘：对比两个时期的记录，说明发生了哪些变化。
+
+【[1] %s %s（%s ~ %s）】
+%s
+
+【[2] %s %s（%s ~ %s）】
+%s
+
+【主题变化（程序预先比对的结果，可参考，也可以修正）】
+%s
+【你的任务】
+- 先用两三句话概括两个时期最大的不同
+- 再分别说明：新出现的关注点、不再出现的关注点、一直延续的关注点（以及它的变化）
+- 最后指出一个值得用户注意的趋势
+- 只能基于上面两份记录，不要补充记录中没有的事实
+
+【引用规则（必须遵守）】
+- 每一句基于记录的论断，句末标注来源编号 [1] 或 [2]，两者都涉及时写 [1][2]
+- 不要使用其它编号
+`, a.Date, a.Type, a.StartDate, a.EndDate, a.Text, b.Date, b.Type, b.StartDate, b.EndDate, b.Text, diff.String())
+}
@@ -7,11 +7,13 @@ import (
 )
 
 const (
-	chatURL  = "http://localhost:8080/v1/chat/completions"
-	embedURL = "http://localhost:11434/v1/embeddings"
+	chatURL   = "http://localhost:8080/v1/chat/completions"
+	embedURL  = "http://localhost:11434/v1/embeddings"
+	rerankURL = "http://localhost:8081/v1/rerank" // 启用 rerank 时的常用地址（默认不启用）
 
-	chatModel  = "qwen2.5-7b-instruct-q5_k_m-00001-of-00002.gguf"
-	embedModel = "nomic-embed-text"
+	chatModel   = "qwen2.5-7b-instruct-q5_k_m-00001-of-00002.gguf"
+	embedModel  = "nomic-embed-text"
+	rerankModel = "bge-reranker-v2-m3"
 )
 
 type Config struct {
@@ -19,6 +21,7 @@ type Config struct {
 	LogDir             string
 	ArchiveDir         string
 	PromptDir          string
+	RecallDir          string
 	DBPath             string
 	Location           *time.Location
 	KeepRawDays        int
@@ -26,6 +29,73 @@ type Config struct {
 	HTTPTimeout        time.Duration
 	SearchTopK         int
 	SearchMinScore     float64
+	EmbedModel         string // 初始 embedding model；切换后以 settings.embed_model 为准
+	EmbedProfiles      map[string]EmbedProfile
+
+	// ranking：时间衰减 / 类型加权 / MMR
+	SearchCandidateK      int                // 进入 MMR 的候选数
+	SearchRecencyHalfLife float64            // 时间衰减半衰期（天）
+	SearchRecencyWeight   float64            // 0 = 不衰减，1 = 完全按半衰期衰减
+	SearchTypeBoost       map[string]float64 // daily|weekly|monthly → 乘数
+	SearchBroadTypeBoost  map[string]float64 // 宽泛问题时额外叠加的乘数
+	SearchMMRLambda       float64            // 1 = 纯相关性，越小越重视多样性
+
+	// 条目级向量聚合：summary 分数 = min(best + w·second, 1)
+	SearchItemSecondWeight float64
+
+	// related：/related 与 daily 生成时的相似时期链接
+	RelatedTopK     int
+	RelatedMinScore float64
+
+	// rerank：llama.cpp /v1/rerank（RerankURL 为空 = 关闭，默认关闭）
+	// 启用：用 --reranking 启动一个加载 reranker 模型的 llama-server，再把 RerankURL 设为 rerankURL
+	RerankURL   string
+	RerankModel string
+	RerankTopN  int // 送入 reranker 的候选数
+
+	// recall：/recall 多轮问答会话
+	RecallHistoryTurns  int     // 改写追问时参考的最近轮数
+	RecallReuseMinScore float64 // 之前命中的记忆与新问题的最低相似度
+	RecallReuseMax      int     // 每轮最多沿用的旧记忆数
+
+	// evidence：/ask 命中 daily 后，下钻到当天原始对话
+	AskEvidenceDays   int // 最多下钻几个 daily 命中
+	AskEvidenceTurns  int // 每天最多取几轮对话
+	AskEvidenceTokens int // 原始证据的总 token 预算
+
+	// evolution：/evolution 主题演变时间线
+	EvolutionMinScore   float64 // 进入时间线的最低余弦相似度
+	EvolutionMaxPeriods int     // 送入 LLM 的最多时期数
+
+	// recurring：/recurring 反复出现的问题
+	RecurringWindowDays   int     // 聚类的时间窗口（天）
+	RecurringSimilarity   float64 // 问题归入同一簇的最低余弦相似度
+	RecurringMinDays      int     // 至少出现在几个不同的日子
+	RecurringResolveScore float64 // 后续 summary 条目与问题的相似度达到该值，视为可能已解决
+
+	// open questions：/questions 未解决问题跟踪
+	OpenQuestionDedupScore   float64 // 字面相似度 ≥ 该值视为同一个问题
+	OpenQuestionResolveScore float64 // 之后 daily 的 highlight 与问题的余弦相似度 ≥ 该值时给出解决建议
+	ChatOpenQuestions        int     // 注入聊天上下文的最多条数（只注入与当前消息相关的）
+	ChatOpenQuestionScore    float64 // 问题与当前消息的余弦相似度 ≥ 该值才视为相关
+
+	// compare：/compare 主题条目的匹配阈值（字面相似度 / 向量余弦，任一达到即视为同一主题）
+	CompareLexicalScore float64
+	CompareThemeScore   float64
+
+	// chat history：messages = 最近几轮作为 user / assistant 消息发送；system = 旧行为（只把 user 行放进 system prompt）
+	ChatHistoryMode  string
+	ChatHistoryTurns int // messages 模式下发送的最近轮数
+
+	// session compaction：当前会话未压缩部分超过 SessionCompactTokens 时，
+	// 除最近 SessionKeepTurns 轮外压缩成“会话至今”摘要（0 = 关闭）
+	SessionCompactTokens int
+	SessionKeepTurns     int
+
+	// prompt budget：chat 上下文块按来源的优先级 / 份额裁剪，避免超出模型上下文被 llama-server 静默截断
+	ChatContextTokens int                    // 模型上下文长度（与 llama-server -c 一致）
+	ChatReserveTokens int                    // 预留给输出的 token
+	PromptBudgets     map[string]BlockBudget // key = PromptBlock.Source（以及 "history"）
 }
 
 func defaultConfig() Config {
@@ -38,6 +108,7 @@ func defaultConfig() Config {
 		LogDir:             filepath.Join(base, "logs"),
 		ArchiveDir:         filepath.Join(base, "logs", "archive"),
 		PromptDir:          filepath.Join(base, "prompts"),
+		RecallDir:          filepath.Join(base, "logs", "recall"),
 		DBPath:             filepath.Join(base, "memory", "memory.sqlite"),
 		Location:           loc,
 		KeepRawDays:        45,
@@ -45,5 +116,64 @@ func defaultConfig() Config {
 		HTTPTimeout:        60 * time.Second,
 		SearchTopK:         5,
 		SearchMinScore:     0.00,
+		EmbedModel:         embedModel,
+		EmbedProfiles:      defaultEmbedProfiles(),
+
+		SearchCandidateK:      30,
+		SearchRecencyHalfLife: 90,
+		SearchRecencyWeight:   0.3,
+		SearchTypeBoost: map[string]float64{
+			"daily":   1.00,
+			"weekly":  1.00,
+			"monthly": 1.00,
+		},
+		SearchBroadTypeBoost: map[string]float64{
+			"weekly":  1.10,
+			"monthly": 1.20,
+		},
+		SearchMMRLambda: 0.7,
+
+		SearchItemSecondWeight: 0.10,
+
+		RelatedTopK:     3,
+		RelatedMinScore: 0.60,
+
+		RerankURL:   "",
+		RerankModel: rerankModel,
+		RerankTopN:  20,
+
+		RecallHistoryTurns:  4,
+		RecallReuseMinScore: 0.50,
+		RecallReuseMax:      2,
+
+		AskEvidenceDays:   2,
+		AskEvidenceTurns:  3,
+		AskEvidenceTokens: 800,
+
+		EvolutionMinScore:   0.45,
+		EvolutionMaxPeriods: 40,
+
+		RecurringWindowDays:   90,
+		RecurringSimilarity:   0.78,
+		RecurringMinDays:      3,
+		RecurringResolveScore: 0.72,
+
+		OpenQuestionDedupScore:   0.60,
+		OpenQuestionResolveScore: 0.72,
+		ChatOpenQuestions:        3,
+		ChatOpenQuestionScore:    0.60,
+
+		CompareLexicalScore: 0.60,
+		CompareThemeScore:   0.80,
+
+		ChatHistoryMode:  chatHistoryMessages,
+		ChatHistoryTurns: 6,
+
+		SessionCompactTokens: 2000,
+		SessionKeepTurns:     3,
+
+		ChatContextTokens: 8192,
+		ChatReserveTokens: 1024,
+		PromptBudgets:     defaultPromptBudgets(),
 	}
 }
@@ -2,6 +2,7 @@ package app
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -37,10 +38,133 @@ CREATE TABLE IF NOT EXISTS embeddings (
   FOREIGN KEY(summary_id) REFERENCES summaries(id) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS settings (
+  key TEXT PRIMARY KEY,
+  value TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
 CREATE INDEX IF NOT EXISTS idx_summaries_type_period ON summaries(type, period_key);
 CREATE INDEX IF NOT EXISTS idx_embeddings_model ON embeddings(model);
 `
 
+// schemaMigrations：在 schemaSQL（v0）之上按顺序执行
+// PRAGMA user_version 记录已执行的条数；只允许追加，不允许修改已发布的条目
+var schemaMigrations = []string{
+	// 1: embeddings 记录被嵌入文本的 hash 与 embedder 身份，用于发现过期向量
+	`
+	ALTER TABLE embeddings ADD COLUMN text_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE embeddings ADD COLUMN embedder TEXT NOT NULL DEFAULT '';
+	`,
+
+	// 2: 一个 summary 可有多条向量（长文本切 chunk），唯一键改为 (summary_id, model, chunk)
+	`
+	CREATE TABLE embeddings_v2 (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  summary_id INTEGER NOT NULL,
+	  model TEXT NOT NULL,
+	  chunk INTEGER NOT NULL DEFAULT 0,
+	  dim INTEGER NOT NULL,
+	  vec BLOB NOT NULL,
+	  l2 REAL NOT NULL,
+	  created_at TEXT NOT NULL,
+	  text_hash TEXT NOT NULL DEFAULT '',
+	  embedder TEXT NOT NULL DEFAULT '',
+	  UNIQUE(summary_id, model, chunk),
+	  FOREIGN KEY(summary_id) REFERENCES summaries(id) ON DELETE CASCADE
+	);
+	INSERT INTO embeddings_v2(id, summary_id, model, chunk, dim, vec, l2, created_at, text_hash, embedder)
+	  SELECT id, summary_id, model, 0, dim, vec, l2, created_at, text_hash, embedder FROM embeddings;
+	DROP TABLE embeddings;
+	ALTER TABLE embeddings_v2 RENAME TO embeddings;
+	CREATE INDEX IF NOT EXISTS idx_embeddings_model ON embeddings(model);
+	CREATE INDEX IF NOT EXISTS idx_embeddings_summary ON embeddings(summary_id, model);
+	`,
+
+	// 3: 条目级向量：field = 来源字段（空 = 整段文本 chunk），item = 条目原文
+	`
+	ALTER TABLE embeddings ADD COLUMN field TEXT NOT NULL DEFAULT '';
+	ALTER TABLE embeddings ADD COLUMN item TEXT NOT NULL DEFAULT '';
+	`,
+
+	// 4: 问题日志：daily 的 open_questions 与当天用户提问（带向量，供 /recurring 聚类）
+	`
+	CREATE TABLE IF NOT EXISTS question_log (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  date TEXT NOT NULL,
+	  source TEXT NOT NULL,
+	  text TEXT NOT NULL,
+	  model TEXT NOT NULL,
+	  dim INTEGER NOT NULL,
+	  vec BLOB NOT NULL,
+	  l2 REAL NOT NULL,
+	  created_at TEXT NOT NULL,
+	  UNIQUE(date, source, text, model)
+	);
+	CREATE INDEX IF NOT EXISTS idx_question_log_model_date ON question_log(model, date);
+	`,
+
+	// 5: 未解决问题跟踪：open_questions（去重后的问题与解决状态）+ sightings（每天的原始表述）
+	`
+	CREATE TABLE IF NOT EXISTS open_questions (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  text TEXT NOT NULL,
+	  first_date TEXT NOT NULL,
+	  last_date TEXT NOT NULL,
+	  status TEXT NOT NULL DEFAULT 'open',
+	  resolved_at TEXT NOT NULL DEFAULT '',
+	  note TEXT NOT NULL DEFAULT '',
+	  suggest_key TEXT NOT NULL DEFAULT '',
+	  suggest_item TEXT NOT NULL DEFAULT '',
+	  suggest_score REAL NOT NULL DEFAULT 0,
+	  created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_open_questions_status ON open_questions(status, first_date);
+	CREATE TABLE IF NOT EXISTS open_question_sightings (
+	  question_id INTEGER NOT NULL,
+	  date TEXT NOT NULL,
+	  text TEXT NOT NULL,
+	  UNIQUE(question_id, date, text),
+	  FOREIGN KEY(question_id) REFERENCES open_questions(id) ON DELETE CASCADE
+	);
+	`,
+
+	// 6: 目标跟踪：weekly 的 next_week_focus / monthly 的 next_month_bets，由下一期 summary 评估
+	`
+	CREATE TABLE IF NOT EXISTS commitments (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  source_type TEXT NOT NULL,
+	  source_key TEXT NOT NULL,
+	  target_key TEXT NOT NULL,
+	  text TEXT NOT NULL,
+	  status TEXT NOT NULL DEFAULT 'pending',
+	  evidence TEXT NOT NULL DEFAULT '',
+	  judged_at TEXT NOT NULL DEFAULT '',
+	  created_at TEXT NOT NULL,
+	  UNIQUE(source_type, source_key, text)
+	);
+	CREATE INDEX IF NOT EXISTS idx_commitments_target ON commitments(source_type, target_key);
+	`,
+
+	// 7: 聊天会话：每条 raw 记录带 session / turn；当前会话保存在 settings.chat_session
+	`
+	CREATE TABLE IF NOT EXISTS sessions (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  title TEXT NOT NULL DEFAULT '',
+	  turns INTEGER NOT NULL DEFAULT 0,
+	  created_at TEXT NOT NULL,
+	  last_active_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_sessions_active ON sessions(last_active_at);
+	`,
+
+	// 8: 长会话滚动压缩：summary = “会话至今”的摘要，summary_turn = 摘要覆盖到的轮次
+	`
+	ALTER TABLE sessions ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+	ALTER TABLE sessions ADD COLUMN summary_turn INTEGER NOT NULL DEFAULT 0;
+	`,
+}
+
 func mustOpenDB(cfg Config) *sql.DB {
 	_ = os.MkdirAll(filepath.Dir(cfg.DBPath), 0755)
 	db, err := sql.Open("sqlite", cfg.DBPath)
@@ -50,9 +174,39 @@ func mustOpenDB(cfg Config) *sql.DB {
 	if _, err := db.Exec(schemaSQL); err != nil {
 		panic(err)
 	}
+	if err := migrateSchema(db); err != nil {
+		panic(err)
+	}
 	return db
 }
 
+func migrateSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(schemaMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(schemaMigrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("schema migration %d failed: %w", i+1, err)
+		}
+		// PRAGMA 不支持参数绑定
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func summaryExists(db *sql.DB, typ, key string) (bool, error) {
 	row := db.QueryRow(`SELECT 1 FROM summaries WHERE type=? AND period_key=? LIMIT 1`, typ, key)
 	var one int
@@ -115,8 +269,58 @@ func loadWeekliesInRange(db *sql.DB, startDate, endDate string) []string {
 	return out
 }
 
-func hasEmbedding(db *sql.DB, summaryID int64, model string) bool {
-	row := db.QueryRow(`SELECT 1 FROM embeddings WHERE summary_id=? AND model=? LIMIT 1`, summaryID, model)
-	var one int
-	return row.Scan(&one) == nil
+type embeddingState int
+
+const (
+	embeddingMissing embeddingState = iota
+	embeddingStale                  // 文本或 embedder 已变化
+	embeddingFresh
+)
+
+// lookupEmbeddingState：任一 chunk 的 hash / embedder 不一致即视为过期
+func lookupEmbeddingState(db *sql.DB, summaryID int64, model, textHash, embedder string) embeddingState {
+	row := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN text_hash=? AND embedder=? THEN 0 ELSE 1 END), 0)
+		FROM embeddings WHERE summary_id=? AND model=?
+	`, textHash, embedder, summaryID, model)
+	var n, stale int
+	if err := row.Scan(&n, &stale); err != nil || n == 0 {
+		return embeddingMissing
+	}
+	if stale > 0 {
+		return embeddingStale
+	}
+	return embeddingFresh
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// getSetting 同时支持 *sql.DB 与 *sql.Tx
+func getSetting(db queryRower, key string) (string, bool) {
+	row := db.QueryRow(`SELECT value FROM settings WHERE key=?`, key)
+	var v string
+	if err := row.Scan(&v); err != nil {
+		return "", false
+	}
+	return v, true
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// setSetting 同时支持 *sql.DB 与 *sql.Tx
+func setSetting(db execer, key, value string) error {
+	_, err := db.Exec(`
+		INSERT INTO settings(key, value, updated_at) VALUES(?,?,?)
+		ON CONFLICT(key) DO UPDATE SET value=excluded.value, updated_at=excluded.updated_at
+	`, key, value, time.Now().Format(time.RFC3339))
+	return err
+}
+
+func deleteSetting(db execer, key string) error {
+	_, err := db.Exec(`DELETE FROM settings WHERE key=?`, key)
+	return err
 }
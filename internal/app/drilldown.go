@@ -0,0 +1,203 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+========================
+Drill-down Evidence
+- /ask 命中 daily summary 时，summary 只有抽象后的 highlights
+- 第二阶段：读取当天原始对话（LogDir 或归档），挑出与问题最相关的轮次作为证据
+- 证据以 Type "raw" 的 SearchHit 参与编号引用，并带上原始日志行号
+========================
+*/
+
+// 单行内容过长时截断（证据只需要能定位原话）
+const evidenceLineRunes = 200
+
+// rawLogLine：原始日志中的一行；Line 为文件中的物理行号（1 起）
+type rawLogLine struct {
+	Line    int
+	Role    string
+	Content string
+	Kind    string
+	Session string
+	Turn    int
+}
+
+// rawTurn：一轮对话 = user 行 + 其后的 assistant 行
+type rawTurn struct {
+	Lines []rawLogLine
+	score float64
+}
+
+// drillDown：对前 AskEvidenceDays 个 daily 命中取原始对话证据（总量受 AskEvidenceTokens 限制）
+func drillDown(cfg Config, hits []SearchHit, question string) []SearchHit {
+	terms := queryTerms(question)
+	if len(terms) == 0 || cfg.AskEvidenceDays <= 0 {
+		return nil
+	}
+
+	budget := cfg.AskEvidenceTokens
+	days := 0
+
+	var out []SearchHit
+	for _, h := range hits {
+		if h.Type != "daily" {
+			continue
+		}
+		if days >= cfg.AskEvidenceDays {
+			break
+		}
+
+		lines, source, err := loadRawTranscript(cfg, h.Date)
+		if err != nil || len(lines) == 0 {
+			continue
+		}
+		days++
+
+		for _, t := range selectTurns(groupRawTurns(lines), terms, cfg.AskEvidenceTurns) {
+			text := t.excerpt()
+			cost := estimateTokens(text)
+			if cost > budget {
+				continue
+			}
+			budget -= cost
+
+			out = append(out, SearchHit{
+				Score:     t.score,
+				Type:      "raw",
+				Date:      h.Date,
+				Text:      text,
+				StartDate: h.Date,
+				EndDate:   h.Date,
+				Doc:       source,
+				LineStart: t.Lines[0].Line,
+				LineEnd:   t.Lines[len(t.Lines)-1].Line,
+			})
+		}
+	}
+	return out
+}
+
+/*
+========================
+Transcript Loading
+========================
+*/
+
+// loadRawTranscript：优先读 LogDir/<date>.jsonl，不存在时回退到月度归档
+func loadRawTranscript(cfg Config, date string) ([]rawLogLine, string, error) {
+	name := date + ".jsonl"
+	if b, err := os.ReadFile(filepath.Join(cfg.LogDir, name)); err == nil {
+		return parseRawLog(b), name, nil
+	}
+
+	b, err := readArchivedDay(cfg, date)
+	if err != nil {
+		return nil, "", err
+	}
+	return parseRawLog(b), filepath.Join("archive", date[:7]+".jsonl.gz", name), nil
+}
+
+// parseRawLog：与 loadRawLinesForDate 相同的解析，但保留物理行号
+func parseRawLog(b []byte) []rawLogLine {
+	var out []rawLogLine
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var r RawLine
+		if err := json.Unmarshal([]byte(line), &r); err != nil || strings.TrimSpace(r.Content) == "" {
+			continue
+		}
+		out = append(out, rawLogLine{Line: i + 1, Role: r.Role, Content: r.Content, Kind: r.Kind, Session: r.Session, Turn: r.Turn})
+	}
+	return out
+}
+
+/*
+========================
+Turn Selection
+========================
+*/
+
+func groupRawTurns(lines []rawLogLine) []rawTurn {
+	var turns []rawTurn
+	for _, l := range lines {
+		// 会话压缩摘要、/ask 问答、/remember 等非对话记录不作为证据
+		if l.Role != "user" && l.Role != "assistant" {
+			continue
+		}
+		if l.Kind != "" && l.Kind != recordKindChat && l.Kind != recordKindPaste {
+			continue
+		}
+		if l.Role == "user" || len(turns) == 0 {
+			turns = append(turns, rawTurn{})
+		}
+		cur := &turns[len(turns)-1]
+		cur.Lines = append(cur.Lines, l)
+	}
+	return turns
+}
+
+// selectTurns：按问题词的覆盖率打分（长词权重更高），取前 n 轮，再按时间顺序排列
+func selectTurns(turns []rawTurn, terms []string, n int) []rawTurn {
+	var total float64
+	for _, t := range terms {
+		total += float64(runeLen(t))
+	}
+
+	var picked []rawTurn
+	for _, t := range turns {
+		var text strings.Builder
+		for _, l := range t.Lines {
+			text.WriteString(strings.ToLower(l.Content))
+			text.WriteString("\n")
+		}
+		s := text.String()
+
+		var hit float64
+		for _, term := range terms {
+			if strings.Contains(s, strings.ToLower(term)) {
+				hit += float64(runeLen(term))
+			}
+		}
+		if hit == 0 {
+			continue
+		}
+		t.score = hit / total
+		picked = append(picked, t)
+	}
+
+	sort.SliceStable(picked, func(i, j int) bool { return picked[i].score > picked[j].score })
+	if n > 0 && len(picked) > n {
+		picked = picked[:n]
+	}
+	sort.SliceStable(picked, func(i, j int) bool { return picked[i].Lines[0].Line < picked[j].Lines[0].Line })
+	return picked
+}
+
+func (t rawTurn) excerpt() string {
+	var b strings.Builder
+	for _, l := range t.Lines {
+		b.WriteString(fmt.Sprintf("L%d %s: %s\n", l.Line, l.Role, truncateRunes(strings.TrimSpace(l.Content), evidenceLineRunes)))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// lineRange：L12 / L12-L15
+func lineRange(h SearchHit) string {
+	if h.LineEnd > h.LineStart {
+		return fmt.Sprintf("L%d-L%d", h.LineStart, h.LineEnd)
+	}
+	return fmt.Sprintf("L%d", h.LineStart)
+}
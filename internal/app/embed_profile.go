@@ -0,0 +1,116 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+========================
+Embedding Profiles
+- 每个 embedding model 的任务前缀与最大输入长度
+- nomic-embed-text 需要 search_query: / search_document: 前缀
+========================
+*/
+
+type EmbedProfile struct {
+	QueryPrefix    string
+	DocumentPrefix string
+	MaxInputRunes  int // 单条输入上限（含前缀之外的正文），超过则切 chunk
+}
+
+// 未配置的 model 使用的兜底 profile
+var defaultEmbedProfile = EmbedProfile{
+	MaxInputRunes: 1500,
+}
+
+func defaultEmbedProfiles() map[string]EmbedProfile {
+	return map[string]EmbedProfile{
+		"nomic-embed-text": {
+			QueryPrefix:    "search_query: ",
+			DocumentPrefix: "search_document: ",
+			MaxInputRunes:  1500,
+		},
+		"mxbai-embed-large": {
+			QueryPrefix:   "Represent this sentence for searching relevant passages: ",
+			MaxInputRunes: 400,
+		},
+		"bge-m3": {
+			MaxInputRunes: 6000,
+		},
+	}
+}
+
+func embedProfile(cfg Config, model string) EmbedProfile {
+	// Ollama 允许 "name:tag"，profile 按 name 匹配
+	name := model
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	if p, ok := cfg.EmbedProfiles[model]; ok {
+		return p
+	}
+	if p, ok := cfg.EmbedProfiles[name]; ok {
+		return p
+	}
+	return defaultEmbedProfile
+}
+
+// signature：profile 变化时，旧向量视为过期
+func (p EmbedProfile) signature() string {
+	return fmt.Sprintf("q=%q,d=%q,max=%d", p.QueryPrefix, p.DocumentPrefix, p.MaxInputRunes)
+}
+
+// chunkForEmbedding：按行打包为 <= maxRunes 的 chunk；单行过长时硬切
+// indexText 是按行拼接的条目，按行切分不会打断语义单元
+func chunkForEmbedding(text string, maxRunes int) []string {
+	text = strings.TrimSpace(text)
+	if maxRunes <= 0 || runeLen(text) <= maxRunes {
+		return []string{text}
+	}
+
+	var (
+		chunks []string
+		cur    []string
+		curLen int
+	)
+
+	flush := func() {
+		if len(cur) > 0 {
+			chunks = append(chunks, strings.Join(cur, "\n"))
+			cur = nil
+			curLen = 0
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		r := []rune(line)
+		for len(r) > maxRunes {
+			flush()
+			chunks = append(chunks, string(r[:maxRunes]))
+			r = r[maxRunes:]
+		}
+		line = string(r)
+
+		n := len(r)
+		if curLen > 0 && curLen+1+n > maxRunes {
+			flush()
+		}
+		cur = append(cur, line)
+		curLen += n
+		if len(cur) > 1 {
+			curLen++
+		}
+	}
+	flush()
+
+	if len(chunks) == 0 {
+		return []string{text}
+	}
+	return chunks
+}
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+========================
+Embedding Model Migration
+- active  model：检索使用（settings.embed_model，默认 cfg.EmbedModel）
+- pending model：/reindex --model 正在构建的并行索引
+- 覆盖率 100% 后才允许 switch；旧向量通过 prune 清理
+========================
+*/
+
+const (
+	settingEmbedModel        = "embed_model"
+	settingEmbedModelPending = "embed_model_pending"
+)
+
+func activeEmbedModel(db *sql.DB, cfg Config) string {
+	if m, ok := getSetting(db, settingEmbedModel); ok && m != "" {
+		return m
+	}
+	return cfg.EmbedModel
+}
+
+func pendingEmbedModel(db *sql.DB) string {
+	m, _ := getSetting(db, settingEmbedModelPending)
+	return m
+}
+
+// indexModels：新 summary 需要写入向量的 model 列表（迁移期间双写）
+func indexModels(db *sql.DB, cfg Config) []string {
+	active := activeEmbedModel(db, cfg)
+	models := []string{active}
+	if p := pendingEmbedModel(db); p != "" && p != active {
+		models = append(models, p)
+	}
+	return models
+}
+
+type EmbeddingCoverage struct {
+	Model   string
+	Covered int
+	Total   int
+	Dims    string
+}
+
+func (c EmbeddingCoverage) Complete() bool {
+	return c.Total > 0 && c.Covered >= c.Total
+}
+
+// embeddingCoverage：每个 model 覆盖了多少 summaries
+func embeddingCoverage(db *sql.DB) (total int, out []EmbeddingCoverage, err error) {
+	if err := db.QueryRow(`SELECT COUNT(*) FROM summaries`).Scan(&total); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT model, COUNT(DISTINCT summary_id), GROUP_CONCAT(DISTINCT dim)
+		FROM embeddings
+		GROUP BY model
+		ORDER BY model
+	`)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c EmbeddingCoverage
+		if err := rows.Scan(&c.Model, &c.Covered, &c.Dims); err != nil {
+			continue
+		}
+		c.Total = total
+		out = append(out, c)
+	}
+	return total, out, rows.Err()
+}
+
+func coverageFor(db *sql.DB, model string) (EmbeddingCoverage, error) {
+	_, all, err := embeddingCoverage(db)
+	if err != nil {
+		return EmbeddingCoverage{}, err
+	}
+	for _, c := range all {
+		if c.Model == model {
+			return c, nil
+		}
+	}
+
+	var total int
+	_ = db.QueryRow(`SELECT COUNT(*) FROM summaries`).Scan(&total)
+	return EmbeddingCoverage{Model: model, Total: total}, nil
+}
+
+// PrintEmbeddingStatus：/embeddings status
+func PrintEmbeddingStatus(db *sql.DB, cfg Config) error {
+	total, all, err := embeddingCoverage(db)
+	if err != nil {
+		return err
+	}
+
+	active := activeEmbedModel(db, cfg)
+	pending := pendingEmbedModel(db)
+
+	fmt.Printf("summaries: %d\n", total)
+	fmt.Printf("active model: %s\n", active)
+	if pending != "" {
+		fmt.Printf("pending model: %s\n", pending)
+	}
+	fmt.Println()
+
+	seenActive := false
+	for _, c := range all {
+		mark := " "
+		switch c.Model {
+		case active:
+			mark = "*"
+			seenActive = true
+		case pending:
+			mark = "+"
+		}
+
+		pct := 0.0
+		if c.Total > 0 {
+			pct = float64(c.Covered) * 100 / float64(c.Total)
+		}
+		fmt.Printf("%s %-32s %5d/%-5d %6.1f%%  dim=%s\n", mark, c.Model, c.Covered, c.Total, pct, c.Dims)
+	}
+	if !seenActive {
+		fmt.Printf("* %-32s %5d/%-5d %6.1f%%\n", active, 0, total, 0.0)
+	}
+
+	return nil
+}
+
+// SwitchEmbedModel：覆盖率完整时原子切换 active model
+func SwitchEmbedModel(db *sql.DB, cfg Config, model string) error {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if model == activeEmbedModel(db, cfg) {
+		return fmt.Errorf("%s is already the active model", model)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 覆盖率检查与切换在同一事务内，避免检查后又有新 summary 写入
+	var missing int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM summaries s
+		WHERE NOT EXISTS (
+			SELECT 1 FROM embeddings e WHERE e.summary_id = s.id AND e.model = ?
+		)
+	`, model).Scan(&missing)
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("model %s is missing %d summaries; run /reindex --model %s all", model, missing, model)
+	}
+
+	if err := setSetting(tx, settingEmbedModel, model); err != nil {
+		return err
+	}
+	// 切换到的正是迁移中的 model：迁移结束（条件删除放在事务内，不读事务外的状态）
+	if _, err := tx.Exec(`DELETE FROM settings WHERE key=? AND value=?`, settingEmbedModelPending, model); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PruneEmbeddings：删除旧 model 的向量
+// model 为空 = 除 active 与迁移中（pending）的 model 之外的全部；迁移中的 model 只能显式指定名字删除
+func PruneEmbeddings(db *sql.DB, cfg Config, model string) (int64, error) {
+	active := activeEmbedModel(db, cfg)
+	if model == active {
+		return 0, fmt.Errorf("refuse to prune the active model %s", model)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		pending, _ = getSetting(tx, settingEmbedModelPending)
+		where      = `WHERE model = ?`
+		args       = []any{model}
+	)
+	if model == "" {
+		where, args = `WHERE model <> ? AND model <> ?`, []any{active, pending}
+	}
+
+	res, err := tx.Exec(`DELETE FROM embeddings `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// question_log 的向量同样按 model 存放（/recurring 需要时会按新 model 补齐）
+	if _, err := tx.Exec(`DELETE FROM question_log `+where, args...); err != nil {
+		return 0, err
+	}
+
+	// 显式删除迁移中的 model = 放弃这次迁移
+	if model != "" && model == pending {
+		if err := deleteSetting(tx, settingEmbedModelPending); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
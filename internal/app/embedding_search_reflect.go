@@ -3,12 +3,13 @@ package app
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -22,6 +23,7 @@ Embedding Response
 
 type embedResp struct {
 	Data []struct {
+		Index     int       `json:"index"`
 		Embedding []float32 `json:"embedding"`
 	} `json:"data"`
 }
@@ -34,70 +36,185 @@ var embedHTTPClient = &http.Client{
 /*
 ========================
 Embedding Writer
-（保持原样）
+- 文档按 profile 加 document 前缀
+- 超过 MaxInputRunes 的文本切成多个 chunk，每个 chunk 一条向量
 ========================
 */
 
+// ensureEmbedding：为 active model（以及迁移中的 pending model）写入向量
 func ensureEmbedding(db *sql.DB, cfg Config, text, typ, key string) error {
-	row := db.QueryRow(`SELECT id FROM summaries WHERE type=? AND period_key=?`, typ, key)
-	var sid int64
-	if err := row.Scan(&sid); err != nil {
+	var firstErr error
+	for _, model := range indexModels(db, cfg) {
+		if err := ensureEmbeddingForModel(db, cfg, model, text, typ, key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ensureEmbeddingForModel：向量不存在或已过期（text hash / embedder 变化）时重新嵌入
+// 向量组成：整段文本 chunk（field 为空）+ 每个列表条目一条（field = 字段名）
+func ensureEmbeddingForModel(db *sql.DB, cfg Config, model, text, typ, key string) error {
+	row := db.QueryRow(`SELECT id, json FROM summaries WHERE type=? AND period_key=?`, typ, key)
+	var (
+		sid int64
+		js  string
+	)
+	if err := row.Scan(&sid, &js); err != nil {
 		return err
 	}
 
-	if hasEmbedding(db, sid, embedModel) {
+	items := extractIndexItems(js)
+	hash := indexHash(text, items)
+	embedder := embedderIdentity(cfg, model)
+	if lookupEmbeddingState(db, sid, model, hash, embedder) == embeddingFresh {
 		return nil
 	}
 
+	prof := embedProfile(cfg, model)
+
+	type vecInput struct {
+		field string
+		item  string
+		input string
+	}
+	var ins []vecInput
+	for _, c := range chunkForEmbedding(text, prof.MaxInputRunes) {
+		ins = append(ins, vecInput{input: prof.DocumentPrefix + c})
+	}
+	for _, it := range items {
+		ins = append(ins, vecInput{
+			field: it.Field,
+			item:  it.Text,
+			input: prof.DocumentPrefix + truncateRunes(it.Text, prof.MaxInputRunes),
+		})
+	}
+
+	inputs := make([]string, len(ins))
+	for i, in := range ins {
+		inputs[i] = in.input
+	}
+
+	vecs, err := requestEmbeddings(model, inputs)
+	if err != nil {
+		return err
+	}
+
+	// 整组替换：chunk / 条目数量可能随文本变化
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM embeddings WHERE summary_id=? AND model=?`, sid, model); err != nil {
+		return err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for i, vec := range vecs {
+		_, err = tx.Exec(`
+			INSERT INTO embeddings(summary_id, model, chunk, field, item, dim, vec, l2, created_at, text_hash, embedder)
+			VALUES(?,?,?,?,?,?,?,?,?,?,?)
+		`, sid, model, i, ins[i].field, ins[i].item, len(vec), encodeVec(vec), l2norm(vec), now, hash, embedder)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if n <= 0 || len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// textHash：被嵌入文本的内容指纹
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// embedderIdentity：同名 model 在不同后端 / 不同 profile 下产出的向量不可混用
+func embedderIdentity(cfg Config, model string) string {
+	return model + "@" + embedURL + "#" + embedProfile(cfg, model).signature()
+}
+
+// encodeVec：float32 little-endian
+func encodeVec(vec []float32) []byte {
+	buf := new(bytes.Buffer)
+	for _, v := range vec {
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// requestEmbedding：单条文本
+func requestEmbedding(model, text string) ([]float32, error) {
+	vecs, err := requestEmbeddings(model, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+// requestEmbeddings：调用 embedding 服务（Ollama OpenAI 兼容接口），按 input 顺序返回
+func requestEmbeddings(model string, inputs []string) ([][]float32, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("empty embedding input")
+	}
+
 	payload := map[string]any{
-		"model": embedModel,
-		"input": text,
+		"model": model,
+		"input": inputs,
 	}
 	b, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req, err := http.NewRequest("POST", embedURL, bytes.NewReader(b))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := embedHTTPClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// ✅ 必须检查状态码，否则 401/500 会被当成“空 embedding”
 	if resp.StatusCode/100 != 2 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("embedding http error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("embedding http error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
 	var er embedResp
 	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
-		return fmt.Errorf("decode embedding response failed: %w", err)
+		return nil, fmt.Errorf("decode embedding response failed: %w", err)
 	}
-	if len(er.Data) == 0 || len(er.Data[0].Embedding) == 0 {
-		return fmt.Errorf("empty embedding")
+	if len(er.Data) != len(inputs) {
+		return nil, fmt.Errorf("embedding count mismatch: want %d, got %d", len(inputs), len(er.Data))
 	}
 
-	vec := er.Data[0].Embedding
-	buf := new(bytes.Buffer)
-	var l2 float64
-	for _, v := range vec {
-		_ = binary.Write(buf, binary.LittleEndian, v)
-		l2 += float64(v * v)
+	out := make([][]float32, len(inputs))
+	for i, d := range er.Data {
+		idx := d.Index
+		if idx < 0 || idx >= len(out) || out[idx] != nil {
+			idx = i
+		}
+		if len(d.Embedding) == 0 {
+			return nil, fmt.Errorf("empty embedding")
+		}
+		out[idx] = d.Embedding
 	}
-	l2 = math.Sqrt(l2)
-
-	_, err = db.Exec(`
-		INSERT INTO embeddings(summary_id, model, dim, vec, l2, created_at)
-		VALUES(?,?,?,?,?,?)
-	`, sid, embedModel, len(vec), buf.Bytes(), l2, time.Now().Format(time.RFC3339))
-	return err
+	return out, nil
 }
 
 /*
@@ -114,8 +231,36 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 /help                         show help
 
 /chat <msg>                   chat with memory context
-/ask <question>               ask with memory context
+/chat --as-of 2025-06-30 <msg>  chat as of a past date (not logged)
+/ask <question>               ask with memory context (streamed)
+/ask --refs <question>        also list the top references
+/ask --json <question>        print {answer, references, model, latency, grounded,
+                              invalid_citations, uncited, scope, as_of} as JSON
+/ask --as-of 2025-06-30 <q>   answer using only memories that ended before that date
+/new [title]                  start a new chat session (history and logs are kept per session)
+/sessions                     list recent sessions (* = current)
+/resume <id>                  switch back to an earlier session
+/rename [#id] <title>         rename the current (or given) session
+/recall [question]            multi-turn Q&A session (follow-ups keep context; /end exits)
+/recall --as-of 2025-06-30    every turn uses only memories that ended before that date
+/ask --session [question]     same as /recall (--as-of works, --json does not)
 /search <query>               semantic search summaries
+/search --explain <query>     show score components (cosine / recency / type / mmr)
+/search --as-of 2025-06-30 <query>  only summaries that ended before that date
+                              time expressions (last March, 上个月, 2025年3月, in Q3) limit /ask and /search
+
+/evolution <topic>            how your thinking on a topic changed over time (cited timeline)
+/evolution --json <topic>     same, as JSON (sections + periods grouped by month)
+
+/compare <periodA> <periodB>  compare two periods (2025-11 2025-12, 2025-W10 2025-W20, mixed ok)
+/recurring [--days N]         questions that keep coming back (default: last 90 days)
+/questions [--all]            unresolved open questions, oldest first (with resolution hints)
+/resolve <id> [note]          mark an open question as resolved
+/goals [weekly|monthly] [--detail]  hit rate of next_week_focus / next_month_bets
+
+/show daily 2025-12-01        print a full summary (weekly 2025-W49, monthly 2025-12)
+/show <type> <key> --raw      print the stored summary JSON
+/related <type> <key> [--top N]  list the most similar other periods
 
 /daily                        generate today's daily summary
 /daily --force                regenerate today's daily summary
@@ -126,23 +271,109 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 /monthly                      generate current month's summary
 /monthly --force              regenerate current month's summary
 
-/reindex daily|weekly|monthly|all   backfill embeddings
+/reindex daily|weekly|monthly|all   backfill missing and refresh stale embeddings
+/reindex --model <name> [type]      build a side-by-side index for another model (default: all)
+/reindex --stale [type]             re-embed vectors whose text or embedder changed (default: all)
+
+/embeddings status            show vector coverage per model
+/embeddings switch <model>    switch active model (requires full coverage)
+/embeddings prune [model]     delete vectors of old models (keeps the model being migrated to unless named)
 
 /remember <fact>              explicitly teach the system a confirmed fact
 /forget <fact>                explicitly retract a previously remembered fact
 
 /paste                        enter multi-line input (empty line submits)
-/debug <msg>                  print composed system prompt (no model call)
+/debug [--as-of date] <msg>   print composed prompt with per-block token counts (no model call)
+/debug --save file.json <msg> also save the prompt (messages array) as JSON
+/replay file.json             resend a saved prompt to the model (not logged)
 `)
 
 		// ---------- DEBUG ----------
 	case strings.HasPrefix(input, "/debug"):
-		msg := strings.TrimSpace(strings.TrimPrefix(input, "/debug"))
+		msg, asOfArg := splitAsOf(strings.TrimPrefix(input, "/debug"))
+		msg, savePath := splitFlag(msg, "--save")
 		if msg == "" {
-			fmt.Println("usage: /debug <msg>")
+			fmt.Println("usage: /debug [--as-of YYYY-MM-DD] [--save file.json] <msg>")
+			return
+		}
+		asOf, err := resolveAsOf(cfg, asOfArg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := DebugChat(cfg, db, msg, asOf, savePath); err != nil {
+			fmt.Println("debug error:", err)
+		}
+
+	// ---------- SESSIONS ----------
+	case input == "/new" || strings.HasPrefix(input, "/new "):
+		sess, err := createSession(db, strings.TrimSpace(strings.TrimPrefix(input, "/new")))
+		if err != nil {
+			fmt.Println("session error:", err)
+			return
+		}
+		lw.SetSession(sess.ID)
+		fmt.Println("🆕 new session", sess.Label())
+
+	case input == "/sessions":
+		cur, err := lw.Session()
+		if err != nil {
+			fmt.Println("session error:", err)
+			return
+		}
+		list, err := listSessions(db, 20)
+		if err != nil {
+			fmt.Println("session error:", err)
 			return
 		}
-		DebugChat(cfg, db, msg)
+		fmt.Println(formatSessions(list, cur.ID, cfg.Location))
+
+	case strings.HasPrefix(input, "/resume"):
+		arg := strings.TrimSpace(strings.TrimPrefix(input, "/resume"))
+		if arg == "" {
+			fmt.Println("usage: /resume <id>")
+			return
+		}
+		id, err := parseSessionID(arg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		sess, err := resumeSession(db, id)
+		if err != nil {
+			fmt.Println("session error:", err)
+			return
+		}
+		lw.SetSession(sess.ID)
+		fmt.Printf("↩️ resumed session %s (%d turns)\n", sess.Label(), sess.Turns)
+
+	case strings.HasPrefix(input, "/rename"):
+		cur, err := lw.Session()
+		if err != nil {
+			fmt.Println("session error:", err)
+			return
+		}
+		id, title, err := parseRenameArgs(strings.TrimPrefix(input, "/rename"), cur.ID)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := renameSession(db, id, title); err != nil {
+			fmt.Println("session error:", err)
+			return
+		}
+		fmt.Printf("✏️ session #%d renamed to「%s」\n", id, title)
+
+	// ---------- REPLAY ----------
+	case strings.HasPrefix(input, "/replay"):
+		path := strings.TrimSpace(strings.TrimPrefix(input, "/replay"))
+		if path == "" {
+			fmt.Println("usage: /replay <file.json>")
+			return
+		}
+		if err := ReplayPrompt(path); err != nil {
+			fmt.Println("replay error:", err)
+		}
 
 	// ---------- PASTE ----------
 	case input == "/paste":
@@ -160,19 +391,35 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 
 		fmt.Println("\nAssistant>")
 		if DefaultUseLongTermChat {
-			if err := Chat(lw, cfg, db, msg); err != nil {
+			if err := chatTurn(lw, cfg, db, msg, time.Time{}, recordKindPaste); err != nil {
 				fmt.Println("chat error:", err)
 			}
 		} else {
-			answer := streamChat(msg)
-			_ = lw.WriteRecord(map[string]string{"role": "user", "content": msg})
-			_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": answer})
+			plainChatTurn(lw, msg, recordKindPaste)
 		}
 
 	// ---------- SEARCH ----------
 	case strings.HasPrefix(input, "/search "):
-		q := strings.TrimPrefix(input, "/search ")
-		hits, err := SearchWithScore(db, cfg, q)
+		q, explain, asOfArg := parseSearchArgs(strings.TrimPrefix(input, "/search "))
+		asOf, err := resolveAsOf(cfg, asOfArg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		q, opts := scopeQuery(cfg, q, asOf)
+		if opts.Scope != nil {
+			fmt.Println("⏱ scope:", opts.Scope)
+		}
+		if !asOf.IsZero() {
+			fmt.Println("⏪ as of:", asOfArg)
+		}
+		if explain {
+			// 过期向量会拉低 cosine，解释分数时一并提示
+			if notice := staleVectorNotice(db, cfg); notice != "" {
+				fmt.Println(notice)
+			}
+		}
+		hits, err := SearchWithOptions(db, cfg, q, opts)
 		if err != nil {
 			fmt.Println("search error:", err)
 			return
@@ -181,27 +428,180 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 			fmt.Println("no related memory")
 			return
 		}
+		terms := queryTerms(q)
 		for _, h := range hits {
 			fmt.Printf("[%.2f] %s %s\n", h.Score, h.Date, h.Type)
-			fmt.Println(h.Text)
+			if m := formatMatch(h); m != "" {
+				fmt.Println("  " + highlightTerms(m, terms))
+			}
+			if explain {
+				fmt.Println("  " + formatExplain(h))
+			}
+			if js, ok := loadSummaryJSON(db, h.Type, h.Date); ok {
+				fmt.Println(renderSummary(h.Type, h.Date, js, terms))
+			} else {
+				fmt.Println(h.Text)
+			}
 			fmt.Println("----------------------")
 		}
 
+	// ---------- RELATED ----------
+	case strings.HasPrefix(input, "/related"):
+		typ, key, top, err := parseRelatedArgs(input, cfg.SearchTopK)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		hits, err := FindRelated(db, cfg, typ, key, relatedOptions{TopN: top})
+		if err != nil {
+			fmt.Println("related error:", err)
+			return
+		}
+		if len(hits) == 0 {
+			fmt.Println("no related memory")
+			return
+		}
+		for i, h := range hits {
+			fmt.Println(formatRefLine(i+1, h))
+		}
+
+	// ---------- SHOW ----------
+	case strings.HasPrefix(input, "/show"):
+		var args []string
+		raw := false
+		for _, p := range strings.Fields(input)[1:] {
+			if p == "--raw" {
+				raw = true
+			} else {
+				args = append(args, p)
+			}
+		}
+		if len(args) != 2 {
+			fmt.Println("usage: /show daily|weekly|monthly <key> [--raw]")
+			return
+		}
+		if err := ShowSummary(db, args[0], args[1], raw); err != nil {
+			fmt.Println("show error:", err)
+		}
+
 	// ---------- ASK ----------
 	case strings.HasPrefix(input, "/ask "):
 		raw := strings.TrimPrefix(input, "/ask ")
-		ans, err := Ask(db, cfg, raw)
+		if args := parseAskArgs(raw); args.Session {
+			if args.JSON {
+				fmt.Println("ask error: --json is not supported with --session")
+				return
+			}
+			asOf, err := resolveAsOf(cfg, args.AsOf)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			RunRecall(lw, cfg, db, reader, args.Question, asOf)
+			return
+		}
+		ans, err := Ask(lw, db, cfg, raw)
 		if err != nil {
 			fmt.Println("ask error:", err)
 			return
 		}
 		fmt.Println(ans)
 
+	// ---------- EVOLUTION ----------
+	case strings.HasPrefix(input, "/evolution"):
+		out, err := Evolution(db, cfg, strings.TrimPrefix(input, "/evolution"))
+		if err != nil {
+			fmt.Println("evolution error:", err)
+			return
+		}
+		fmt.Println(out)
+
+	// ---------- RECURRING ----------
+	case input == "/recurring" || strings.HasPrefix(input, "/recurring "):
+		days, err := parseRecurringArgs(strings.TrimPrefix(input, "/recurring"), cfg.RecurringWindowDays)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		clusters, err := FindRecurring(db, cfg, days)
+		if err != nil {
+			fmt.Println("recurring error:", err)
+			return
+		}
+		fmt.Println(formatRecurring(clusters, cfg, days))
+
+	// ---------- OPEN QUESTIONS ----------
+	case input == "/questions" || input == "/questions --all":
+		qs, err := listOpenQuestions(db, input == "/questions --all")
+		if err != nil {
+			fmt.Println("questions error:", err)
+			return
+		}
+		fmt.Println(formatOpenQuestions(qs, time.Now().In(cfg.Location)))
+
+	case strings.HasPrefix(input, "/resolve"):
+		id, note, err := parseResolveArgs(strings.TrimPrefix(input, "/resolve"))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := ResolveOpenQuestion(db, cfg, id, note); err != nil {
+			fmt.Println("resolve error:", err)
+			return
+		}
+		fmt.Printf("[ok] question #%d resolved\n", id)
+
+	// ---------- GOALS ----------
+	case input == "/goals" || strings.HasPrefix(input, "/goals "):
+		typ, detail := "", false
+		for _, p := range strings.Fields(input)[1:] {
+			if p == "--detail" {
+				detail = true
+			} else {
+				typ = p
+			}
+		}
+		out, err := GoalsReport(db, typ, detail)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(out)
+
+	// ---------- COMPARE ----------
+	case strings.HasPrefix(input, "/compare"):
+		args := strings.Fields(strings.TrimPrefix(input, "/compare"))
+		if len(args) != 2 {
+			fmt.Println("usage: /compare <periodA> <periodB>   e.g. /compare 2025-11 2025-12")
+			return
+		}
+		out, err := Compare(db, cfg, args[0], args[1])
+		if err != nil {
+			fmt.Println("compare error:", err)
+			return
+		}
+		fmt.Println(out)
+
+	// ---------- RECALL ----------
+	case input == "/recall" || strings.HasPrefix(input, "/recall "):
+		first, asOfArg := splitAsOf(strings.TrimPrefix(input, "/recall"))
+		asOf, err := resolveAsOf(cfg, asOfArg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		RunRecall(lw, cfg, db, reader, first, asOf)
+
 	// ---------- CHAT ----------
 	case strings.HasPrefix(input, "/chat "):
-		raw := strings.TrimPrefix(input, "/chat ")
+		raw, asOfArg := splitAsOf(strings.TrimPrefix(input, "/chat "))
+		asOf, err := resolveAsOf(cfg, asOfArg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 		fmt.Println("\nAssistant>")
-		if err := Chat(lw, cfg, db, raw); err != nil {
+		if err := ChatAsOf(lw, cfg, db, raw, asOf); err != nil {
 			fmt.Println("chat error:", err)
 		}
 
@@ -262,16 +662,69 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 
 	// ---------- REINDEX ----------
 	case strings.HasPrefix(input, "/reindex"):
+		if err := Reindex(db, cfg, parseReindexArgs(input)); err != nil {
+			fmt.Println("reindex error:", err)
+		}
+
+	// ---------- EMBEDDINGS ----------
+	case strings.HasPrefix(input, "/embeddings"):
 		parts := strings.Fields(input)
-		target := "daily"
+		sub := "status"
 		if len(parts) > 1 {
-			target = parts[1]
+			sub = parts[1]
 		}
-		if err := Reindex(db, cfg, target); err != nil {
-			fmt.Println("reindex error:", err)
+		arg := ""
+		if len(parts) > 2 {
+			arg = parts[2]
+		}
+
+		switch sub {
+		case "status":
+			if err := PrintEmbeddingStatus(db, cfg); err != nil {
+				fmt.Println("embeddings error:", err)
+			}
+		case "switch":
+			if err := SwitchEmbedModel(db, cfg, arg); err != nil {
+				fmt.Println("switch error:", err)
+				return
+			}
+			fmt.Println("[ok] active embedding model:", arg)
+		case "prune":
+			n, err := PruneEmbeddings(db, cfg, arg)
+			if err != nil {
+				fmt.Println("prune error:", err)
+				return
+			}
+			fmt.Printf("[ok] pruned %d vectors\n", n)
+		default:
+			fmt.Println("usage: /embeddings status|switch <model>|prune [model]")
 		}
 
 	default:
 		fmt.Println("unknown command, try /help")
 	}
 }
+
+// /reindex [--model <name>] [--stale] [daily|weekly|monthly|all]
+func parseReindexArgs(input string) ReindexOptions {
+	var opts ReindexOptions
+	parts := strings.Fields(input)
+	for i := 1; i < len(parts); i++ {
+		switch {
+		case parts[i] == "--model" && i+1 < len(parts):
+			opts.Model = parts[i+1]
+			i++
+		case parts[i] == "--stale":
+			opts.Stale = true
+		default:
+			opts.Type = parts[i]
+		}
+	}
+	if opts.Type == "" {
+		opts.Type = "daily"
+		if opts.Model != "" || opts.Stale {
+			opts.Type = "all"
+		}
+	}
+	return opts
+}
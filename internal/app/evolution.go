@@ -0,0 +1,300 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Topic Evolution (/evolution)
+- 取出与主题相关（余弦 ≥ EvolutionMinScore）的所有 summary
+- 按 start_date 排序、按月分组，交给 LLM 写成带引用的时间线叙述
+- 叙述固定分为四节，--json 时按小节拆开
+========================
+*/
+
+type EvolutionResult struct {
+	Topic         string           `json:"topic"`
+	Scope         string           `json:"scope,omitempty"`
+	Narrative     string           `json:"narrative"`      // 时间线
+	TurningPoints string           `json:"turning_points"` // 转折点
+	Reversals     string           `json:"reversals"`      // 反复与推翻
+	Unresolved    string           `json:"unresolved"`     // 仍未解决
+	Months        []EvolutionMonth `json:"months"`
+
+	Grounded         bool  `json:"grounded"`
+	InvalidCitations []int `json:"invalid_citations"`
+}
+
+type EvolutionMonth struct {
+	Month   string            `json:"month"` // 2025-03
+	Periods []EvolutionPeriod `json:"periods"`
+}
+
+type EvolutionPeriod struct {
+	N         int     `json:"n"` // prompt 中的引用编号
+	Type      string  `json:"type"`
+	Key       string  `json:"key"`
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Score     float64 `json:"score"`
+	Text      string  `json:"text"`
+	Cited     bool    `json:"cited"`
+}
+
+// 叙述的四个小节（prompt 要求的标题，与 EvolutionResult 字段一一对应）
+var evolutionSections = []string{"时间线", "转折点", "反复与推翻", "仍未解决"}
+
+// Evolution：/evolution [--json] <topic>
+func Evolution(db *sql.DB, cfg Config, input string) (string, error) {
+	topic, asJSON := parseEvolutionArgs(input)
+	if topic == "" {
+		return "", fmt.Errorf("usage: /evolution [--json] <topic>")
+	}
+
+	query, opts := scopeQuery(cfg, topic, time.Time{})
+	hits, err := topicTimeline(db, cfg, query, opts.Scope)
+	if err != nil {
+		return "", err
+	}
+	if len(hits) == 0 {
+		return "", fmt.Errorf("no summaries related to %q (min score %.2f)", topic, cfg.EvolutionMinScore)
+	}
+
+	months := groupByMonth(hits)
+	prompt := buildEvolutionPrompt(topic, formatTimelineMemories(months))
+
+	if !asJSON {
+		_, check, err := streamAnswer(prompt, len(hits))
+		if err != nil {
+			return "", err
+		}
+		return formatCitationFooter(hits, check), nil
+	}
+
+	answer, err := callLLMNonStream(prompt)
+	if err != nil {
+		return "", err
+	}
+	check := checkCitations(answer, len(hits))
+	cited := make(map[int]bool, len(check.Cited))
+	for _, n := range check.Cited {
+		cited[n] = true
+	}
+
+	sections := splitSections(answer, evolutionSections)
+	res := EvolutionResult{
+		Topic:            topic,
+		Scope:            formatScope(opts.Scope),
+		Narrative:        sections[0],
+		TurningPoints:    sections[1],
+		Reversals:        sections[2],
+		Unresolved:       sections[3],
+		Months:           months,
+		Grounded:         check.Grounded,
+		InvalidCitations: check.Invalid,
+	}
+	for i := range res.Months {
+		for j := range res.Months[i].Periods {
+			p := &res.Months[i].Periods[j]
+			p.Cited = cited[p.N]
+		}
+	}
+	if res.InvalidCitations == nil {
+		res.InvalidCitations = []int{}
+	}
+
+	b, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+/*
+========================
+Retrieval
+========================
+*/
+
+// topicTimeline：主题相关的全部 summary（不做时间衰减 / MMR），按 start_date 升序
+// 超过 EvolutionMaxPeriods 时保留分数最高的部分
+func topicTimeline(db *sql.DB, cfg Config, topic string, scope *TimeScope) ([]SearchHit, error) {
+	model := activeEmbedModel(db, cfg)
+	qv, qn, err := embedText(cfg, model, topic)
+	if err != nil {
+		return nil, err
+	}
+	if qn == 0 {
+		return nil, nil
+	}
+
+	cands, err := matchSummaries(db, model, qv, qn)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := loadSummaryRows(db, candidateIDs(cands, cfg.SearchItemSecondWeight, cfg.EvolutionMinScore))
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []SearchHit
+	for _, r := range rows {
+		if scope != nil && !scope.overlaps(r.StartDate, r.EndDate) {
+			continue
+		}
+		h := SearchHit{ID: r.ID, Type: r.Type, Date: r.Key, StartDate: r.StartDate, EndDate: r.EndDate}
+		h.Cosine = cands[r.ID].score(cfg.SearchItemSecondWeight)
+		h.Score = h.Cosine
+		h.Text = extractHumanText(r.JSON)
+		hits = append(hits, h)
+	}
+
+	if cfg.EvolutionMaxPeriods > 0 && len(hits) > cfg.EvolutionMaxPeriods {
+		sortHitsByScore(hits)
+		hits = hits[:cfg.EvolutionMaxPeriods]
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].StartDate != hits[j].StartDate {
+			return hits[i].StartDate < hits[j].StartDate
+		}
+		// 同一天开始时，范围大的（monthly / weekly）在前
+		return hits[i].EndDate > hits[j].EndDate
+	})
+	return hits, nil
+}
+
+// groupByMonth：按 start_date 的月份分组；N 与 hits 下标一致（1 起）
+func groupByMonth(hits []SearchHit) []EvolutionMonth {
+	var months []EvolutionMonth
+	for i, h := range hits {
+		month := h.StartDate
+		if len(month) >= 7 {
+			month = month[:7]
+		}
+		if len(months) == 0 || months[len(months)-1].Month != month {
+			months = append(months, EvolutionMonth{Month: month})
+		}
+		cur := &months[len(months)-1]
+		cur.Periods = append(cur.Periods, EvolutionPeriod{
+			N:         i + 1,
+			Type:      h.Type,
+			Key:       h.Date,
+			StartDate: h.StartDate,
+			EndDate:   h.EndDate,
+			Score:     h.Score,
+			Text:      h.Text,
+		})
+	}
+	return months
+}
+
+/*
+========================
+Prompt
+========================
+*/
+
+func formatTimelineMemories(months []EvolutionMonth) string {
+	var b strings.Builder
+	for _, m := range months {
+		b.WriteString("## " + m.Month + "\n")
+		for _, p := range m.Periods {
+			b.WriteString(fmt.Sprintf("[%d] %s %s（%s ~ %s）\n%s\n\n", p.N, p.Key, p.Type, p.StartDate, p.EndDate, p.Text))
+		}
+	}
+	return b.String()
+}
+
+func buildEvolutionPrompt(topic, timeline string) string {
+	return fmt.Sprintf(`
+你在帮助用户回顾“自己对一个主题的理解是如何演变的”。下面是用户历史记录中与该主题相关的时期，已按时间顺序、按月分组，每段前的 [n] 是引用编号。
+
+【主题】
+%s
+
+【按时间排列的记录】
+%s
+【你的任务】
+按时间顺序写一段叙述，说明用户在这个主题上的做法和想法是如何一步步变化的。
+只能基于上面的记录，不要补充记录中没有的事实。
+
+【输出格式（必须使用以下四个标题）】
+## 时间线
+按月份叙述变化过程，每个时期都要引用对应编号。
+## 转折点
+想法或做法明显改变的时刻，说明改变前后的差异。
+## 反复与推翻
+后来被推翻、放弃或来回摇摆的观点；没有就写“无”。
+## 仍未解决
+到最近一次记录为止仍悬而未决的问题；没有就写“无”。
+
+【引用规则（必须遵守）】
+- 每一句基于记录的论断，句末都要标注来源编号，例如：你在 3 月初改用了 WAL 模式[4]。
+- 一句话来自多段记录时写成 [1][3]
+- 只能使用上面出现过的编号，不要编造编号
+`, topic, timeline)
+}
+
+// splitSections：按 "## 标题" 拆分；第一个标题之前的内容（以及找不到任何标题时的全文）归入第一节
+func splitSections(text string, titles []string) []string {
+	out := make([]string, len(titles))
+	cur := 0
+	var b strings.Builder
+
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			if out[cur] != "" {
+				s = out[cur] + "\n\n" + s
+			}
+			out[cur] = s
+		}
+		b.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		t := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		idx := -1
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			for i, title := range titles {
+				if strings.HasPrefix(t, title) {
+					idx = i
+					break
+				}
+			}
+		}
+		if idx >= 0 {
+			flush()
+			cur = idx
+			continue
+		}
+		b.WriteString(line + "\n")
+	}
+	flush()
+	return out
+}
+
+/*
+========================
+Argument Parser
+========================
+*/
+
+func parseEvolutionArgs(input string) (topic string, asJSON bool) {
+	var q []string
+	for _, p := range strings.Fields(input) {
+		if p == "--json" {
+			asJSON = true
+		} else {
+			q = append(q, p)
+		}
+	}
+	return strings.Join(q, " "), asJSON
+}
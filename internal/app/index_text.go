@@ -61,3 +61,110 @@ func extractIndexText(summaryJSON string) string {
 	}
 	return text
 }
+
+/*
+========================
+Per-field Index Items
+- 每个列表条目（highlight / open question / decision ...）单独成为一条向量
+- field 记录条目来自哪个字段，供检索结果展示
+========================
+*/
+
+type IndexItem struct {
+	Field string
+	Text  string
+}
+
+// 参与条目级索引的字段（覆盖 daily / weekly / monthly 三种 schema）
+var indexItemFields = []string{
+	// daily
+	"topics",
+	"patterns",
+	"open_questions",
+	"highlights",
+	"lowlights",
+	// weekly
+	"themes",
+	"progress",
+	"recurring_blockers",
+	"notable_decisions",
+	"next_week_focus",
+	// monthly
+	"trajectory",
+	"top_themes",
+	"wins",
+	"losses",
+	"systems_improvements",
+	"next_month_bets",
+	// 兼容旧数据
+	"tags",
+	"projects",
+	"decisions",
+	"memory_candidates",
+}
+
+func extractIndexItems(summaryJSON string) []IndexItem {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(summaryJSON), &m); err != nil {
+		return nil
+	}
+
+	var items []IndexItem
+	seen := make(map[string]bool)
+
+	for _, field := range indexItemFields {
+		list, ok := m[field].([]any)
+		if !ok {
+			continue
+		}
+		for _, it := range list {
+			text := strings.TrimSpace(itemText(it))
+			if runeLen(text) < 2 {
+				continue
+			}
+			k := field + "\x00" + text
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			items = append(items, IndexItem{Field: field, Text: text})
+		}
+	}
+	return items
+}
+
+// itemText：条目可能是字符串，也可能是对象（旧数据 memory_candidates 等）
+func itemText(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case map[string]any:
+		for _, k := range []string{"content", "text", "title", "item"} {
+			if s, ok := x[k].(string); ok {
+				return s
+			}
+		}
+		var parts []string
+		for _, vv := range x {
+			if s, ok := vv.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "；")
+	default:
+		return ""
+	}
+}
+
+// indexHash：整段文本 + 条目集合的指纹，任一变化都需要重新嵌入
+func indexHash(text string, items []IndexItem) string {
+	var b strings.Builder
+	b.WriteString(text)
+	for _, it := range items {
+		b.WriteString("\x00")
+		b.WriteString(it.Field)
+		b.WriteString("\x01")
+		b.WriteString(it.Text)
+	}
+	return textHash(b.String())
+}
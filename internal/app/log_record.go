@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"time"
+)
+
+/*
+========================
+Log Record (raw JSONL schema)
+- v0：旧记录，只有 role / content（048 起带 session / turn）
+- v1：LogRecord —— id(ULID) / ts(RFC3339 UTC) / kind / model / 生成参数 / 延迟 / token 用量
+- 读取方只依赖 role / content / session / turn，新旧格式都能解析；字段只增不改
+========================
+*/
+
+const logRecordVersion = 1
+
+// 记录来源
+const (
+	recordKindChat     = "chat"
+	recordKindPaste    = "paste"
+	recordKindRemember = "remember"
+	recordKindForget   = "forget"
+	recordKindAsk      = "ask" // /ask 与 /recall 的问答
+
+	// 长会话滚动压缩（role = system），见 session_compact.go
+	recordKindCompaction = "compaction"
+)
+
+type LogRecord struct {
+	V       int    `json:"v"`
+	ID      string `json:"id"`
+	TS      string `json:"ts"`
+	Role    string `json:"role"`
+	Kind    string `json:"kind,omitempty"`
+	Content string `json:"content"`
+
+	// session / turn 与 v0 记录一致使用字符串
+	Session string `json:"session,omitempty"`
+	Turn    int    `json:"turn,omitempty"`
+
+	// 仅 assistant 记录
+	Model     string         `json:"model,omitempty"`
+	Params    map[string]any `json:"params,omitempty"`
+	LatencyMS int64          `json:"latency_ms,omitempty"`
+	Usage     *TokenUsage    `json:"usage,omitempty"`
+
+	// 仅 ask 记录（assistant）：回答中引用的记忆（"daily 2025-03-01"）
+	Refs []string `json:"refs,omitempty"`
+
+	// 仅 compaction 记录：摘要覆盖到的轮次（含）
+	CoversTurn int `json:"covers_turn,omitempty"`
+}
+
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// assistantRecord：把一次模型调用的结果转成 assistant 记录
+func assistantRecord(kind string, c chatCompletion) LogRecord {
+	return LogRecord{
+		Role:      "assistant",
+		Kind:      kind,
+		Content:   c.Content,
+		Model:     c.Model,
+		Params:    c.Params,
+		LatencyMS: c.Latency.Milliseconds(),
+		Usage:     c.Usage,
+	}
+}
+
+/*
+========================
+ULID
+========================
+*/
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID：48 bit 毫秒时间戳 + 80 bit 随机数，Crockford base32 编码为 26 个字符（按时间可排序）
+func newULID(t time.Time) string {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	_, _ = rand.Read(b[6:])
+
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[i+8])
+	}
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordBase32[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+/*
+========================
+Readers
+========================
+*/
+
+// compactTranscript：给总结模型的原始对话只保留 role / content（以及记录时间、所属会话），去掉元数据
+// 会话压缩记录的 role 改为 session_summary；session 用 labels 中的 "#id「title」"；非 JSON 行原样保留
+func compactTranscript(raw []byte, loc *time.Location, labels map[string]string) []byte {
+	var b bytes.Buffer
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var r LogRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			b.Write(line)
+			b.WriteByte('\n')
+			continue
+		}
+
+		out := map[string]string{"role": r.Role, "content": r.Content}
+		if r.Kind == recordKindCompaction {
+			out["role"] = "session_summary"
+		}
+		if t, err := time.Parse(time.RFC3339, r.TS); err == nil {
+			out["time"] = t.In(loc).Format("15:04")
+		}
+		if r.Session != "" {
+			out["session"] = "#" + r.Session
+			if l, ok := labels[r.Session]; ok {
+				out["session"] = l
+			}
+		}
+		js, err := json.Marshal(out)
+		if err != nil {
+			continue
+		}
+		b.Write(js)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
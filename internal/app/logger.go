@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -14,6 +15,7 @@ type LogWriter struct {
 	db         *sql.DB
 	file       *os.File
 	currentDay string
+	session    int64 // 当前会话（第一次写入时解析一次；/new /resume 通过 SetSession 更新）
 }
 
 func NewLogWriter(cfg Config, db *sql.DB) *LogWriter {
@@ -23,6 +25,24 @@ func NewLogWriter(cfg Config, db *sql.DB) *LogWriter {
 	}
 }
 
+// Session：当前会话；只在第一次调用时读取 settings（不存在时新建）
+func (lw *LogWriter) Session() (ChatSession, error) {
+	if lw.session == 0 {
+		s, err := currentSession(lw.db)
+		if err != nil {
+			return s, err
+		}
+		lw.session = s.ID
+		return s, nil
+	}
+	return loadSession(lw.db, lw.session)
+}
+
+// SetSession：/new /resume 切换会话后调用
+func (lw *LogWriter) SetSession(id int64) {
+	lw.session = id
+}
+
 func (lw *LogWriter) Close() {
 	if lw.file != nil {
 		_ = lw.file.Close()
@@ -30,7 +50,8 @@ func (lw *LogWriter) Close() {
 	}
 }
 
-func (lw *LogWriter) WriteRecord(rec map[string]string) error {
+// WriteRecord：追加一条不可变记录；id / ts / session / turn 由这里补齐，调用方只需填 role / kind / content（及模型元数据）
+func (lw *LogWriter) WriteRecord(rec LogRecord) error {
 	now := time.Now().In(lw.cfg.Location)
 	today := now.Format("2006-01-02")
 
@@ -94,12 +115,20 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 	}
 
 	// ---------- ✅ UTF-8 清洗（关键修复点） ----------
-	clean := make(map[string]string, len(rec))
-	for k, v := range rec {
-		clean[k] = sanitizeUTF8(v)
+	rec.Content = sanitizeUTF8(rec.Content)
+
+	// ---------- 版本 / id / 时间 ----------
+	rec.V = logRecordVersion
+	rec.ID = newULID(now)
+	rec.TS = now.UTC().Format(time.RFC3339)
+
+	// ---------- 会话 / 轮次（跨天不中断） ----------
+	// 失败时仍然写入记录（不带 session / turn），只提示，不丢对话
+	if err := lw.stampSession(&rec); err != nil {
+		fmt.Println("[warn] stamp chat session failed:", err)
 	}
 
-	b, err := json.Marshal(clean)
+	b, err := json.Marshal(rec)
 	if err != nil {
 		return err
 	}
@@ -107,3 +136,18 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 	_, err = lw.file.Write(append(b, '\n'))
 	return err
 }
+
+func (lw *LogWriter) stampSession(rec *LogRecord) error {
+	if lw.session == 0 {
+		if _, err := lw.Session(); err != nil {
+			return err
+		}
+	}
+	turn, err := stampTurn(lw.db, lw.session, rec.Role)
+	if err != nil {
+		return err
+	}
+	rec.Session = strconv.FormatInt(lw.session, 10)
+	rec.Turn = turn
+	return nil
+}
@@ -0,0 +1,430 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+========================
+Open Question Tracker
+- 每个新 daily 的 open_questions 写入 open_questions 表（与现有未解决问题做字面模糊去重）
+- 之后的 daily 的 highlights 与某个问题足够相似时，记录为“解决建议”（由用户 /resolve 确认）
+- /questions 按时长列出未解决问题；BuildChatContext 注入与当前消息相关的最老几条
+========================
+*/
+
+const (
+	openQuestionOpen     = "open"
+	openQuestionResolved = "resolved"
+)
+
+type OpenQuestion struct {
+	ID         int64
+	Text       string
+	FirstDate  string
+	LastDate   string
+	Seen       int // 出现在几个不同的日子
+	Status     string
+	ResolvedAt string
+	Note       string
+
+	SuggestKey   string // 可能回答了它的 daily
+	SuggestItem  string
+	SuggestScore float64
+}
+
+/*
+========================
+Tracking (daily job)
+========================
+*/
+
+// trackOpenQuestions：daily 生成后调用（重新生成同一天是幂等的）
+func trackOpenQuestions(cfg Config, db *sql.DB, date string) error {
+	js, ok := loadSummaryJSON(db, "daily", date)
+	if !ok {
+		return nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(js), &obj); err != nil {
+		return err
+	}
+
+	var items []string
+	if list, ok := obj["open_questions"].([]any); ok {
+		for _, it := range list {
+			if t := strings.TrimSpace(itemText(it)); runeLen(t) >= 4 {
+				items = append(items, t)
+			}
+		}
+	}
+
+	if err := recordOpenQuestions(cfg, db, date, items); err != nil {
+		return err
+	}
+	return suggestResolutions(cfg, db, date)
+}
+
+func recordOpenQuestions(cfg Config, db *sql.DB, date string, items []string) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 现有未解决问题的所有表述（问题本身 + 每次出现时的原文）
+	rows, err := tx.Query(`
+		SELECT q.id, q.text FROM open_questions q WHERE q.status = ?
+		UNION ALL
+		SELECT s.question_id, s.text FROM open_question_sightings s
+		JOIN open_questions q ON q.id = s.question_id WHERE q.status = ?
+	`, openQuestionOpen, openQuestionOpen)
+	if err != nil {
+		return err
+	}
+	type phrasing struct {
+		id   int64
+		text string
+	}
+	var known []phrasing
+	for rows.Next() {
+		var p phrasing
+		if rows.Scan(&p.id, &p.text) == nil {
+			known = append(known, p)
+		}
+	}
+	rows.Close()
+
+	now := time.Now().Format(time.RFC3339)
+	for _, item := range items {
+		var (
+			id   int64
+			best = cfg.OpenQuestionDedupScore
+		)
+		for _, p := range known {
+			if s := textSimilarity(item, p.text); s >= best {
+				id, best = p.id, s
+			}
+		}
+
+		if id == 0 {
+			res, err := tx.Exec(`
+				INSERT INTO open_questions(text, first_date, last_date, created_at)
+				VALUES(?, ?, ?, ?)
+			`, item, date, date, now)
+			if err != nil {
+				return err
+			}
+			if id, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			known = append(known, phrasing{id: id, text: item})
+		} else {
+			if _, err := tx.Exec(`
+				UPDATE open_questions
+				SET first_date = MIN(first_date, ?), last_date = MAX(last_date, ?)
+				WHERE id = ?
+			`, date, date, id); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO open_question_sightings(question_id, date, text) VALUES(?, ?, ?)
+		`, id, date, item); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// suggestResolutions：当天 daily 的 highlights 是否回答了之前的未解决问题
+// 当天仍被提出的问题不算（说明还没解决）
+func suggestResolutions(cfg Config, db *sql.DB, date string) error {
+	model := activeEmbedModel(db, cfg)
+
+	rows, err := db.Query(`
+		SELECT e.item, e.vec, e.dim
+		FROM embeddings e
+		JOIN summaries s ON s.id = e.summary_id
+		WHERE s.type = 'daily' AND s.period_key = ? AND e.model = ? AND e.field = 'highlights'
+	`, date, model)
+	if err != nil {
+		return err
+	}
+	type highlight struct {
+		item string
+		vec  []float32
+	}
+	var hs []highlight
+	for rows.Next() {
+		var (
+			h    highlight
+			blob []byte
+			dim  int
+		)
+		if rows.Scan(&h.item, &blob, &dim) != nil {
+			continue
+		}
+		if v, ok := decodeVec(blob, dim); ok {
+			h.vec = v
+			hs = append(hs, h)
+		}
+	}
+	rows.Close()
+	if len(hs) == 0 {
+		return nil
+	}
+
+	qs, err := listOpenQuestions(db, false)
+	if err != nil {
+		return err
+	}
+	var pending []OpenQuestion
+	for _, q := range qs {
+		if q.FirstDate < date && q.LastDate < date {
+			pending = append(pending, q)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	prefix := embedProfile(cfg, model).QueryPrefix
+	inputs := make([]string, len(pending))
+	for i, q := range pending {
+		inputs[i] = prefix + q.Text
+	}
+	vecs, err := requestEmbeddings(model, inputs)
+	if err != nil {
+		return err
+	}
+
+	for i, q := range pending {
+		best, bestItem := q.SuggestScore, ""
+		for _, h := range hs {
+			if c := cosineF32(vecs[i], h.vec); c >= cfg.OpenQuestionResolveScore && c > best {
+				best, bestItem = c, h.item
+			}
+		}
+		if bestItem == "" {
+			continue
+		}
+		if _, err := db.Exec(`
+			UPDATE open_questions SET suggest_key = ?, suggest_item = ?, suggest_score = ? WHERE id = ?
+		`, date, bestItem, best, q.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+========================
+Queries / Commands
+========================
+*/
+
+// listOpenQuestions：按首次出现时间排序（最老的在前）；all = 包含已解决
+func listOpenQuestions(db *sql.DB, all bool) ([]OpenQuestion, error) {
+	where := `WHERE q.status = 'open'`
+	if all {
+		where = ``
+	}
+	rows, err := db.Query(`
+		SELECT q.id, q.text, q.first_date, q.last_date, q.status, q.resolved_at, q.note,
+		       q.suggest_key, q.suggest_item, q.suggest_score,
+		       (SELECT COUNT(DISTINCT s.date) FROM open_question_sightings s WHERE s.question_id = q.id)
+		FROM open_questions q
+		` + where + `
+		ORDER BY q.first_date, q.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OpenQuestion
+	for rows.Next() {
+		var q OpenQuestion
+		if err := rows.Scan(&q.ID, &q.Text, &q.FirstDate, &q.LastDate, &q.Status, &q.ResolvedAt, &q.Note,
+			&q.SuggestKey, &q.SuggestItem, &q.SuggestScore, &q.Seen); err != nil {
+			continue
+		}
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
+
+// ResolveOpenQuestion：/resolve <id> [note]
+func ResolveOpenQuestion(db *sql.DB, cfg Config, id int64, note string) error {
+	today := time.Now().In(cfg.Location).Format("2006-01-02")
+	res, err := db.Exec(`
+		UPDATE open_questions SET status = ?, resolved_at = ?, note = ? WHERE id = ? AND status = ?
+	`, openQuestionResolved, today, strings.TrimSpace(note), id, openQuestionOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no open question #%d", id)
+	}
+	return nil
+}
+
+func formatOpenQuestions(qs []OpenQuestion, now time.Time) string {
+	if len(qs) == 0 {
+		return "no open questions"
+	}
+
+	var b strings.Builder
+	for _, q := range qs {
+		age := ""
+		if d, err := time.ParseInLocation("2006-01-02", q.FirstDate, now.Location()); err == nil {
+			age = fmt.Sprintf("%dd", int(now.Sub(d).Hours()/24))
+		}
+		b.WriteString(fmt.Sprintf("#%d [%s] %s\n", q.ID, age, q.Text))
+		b.WriteString(fmt.Sprintf("    first %s · last %s · seen on %d days", q.FirstDate, q.LastDate, q.Seen))
+		if q.Status == openQuestionResolved {
+			b.WriteString(" · ✅ resolved " + q.ResolvedAt)
+			if q.Note != "" {
+				b.WriteString("：" + q.Note)
+			}
+		}
+		b.WriteString("\n")
+		if q.Status == openQuestionOpen && q.SuggestKey != "" {
+			b.WriteString(fmt.Sprintf("    💡 可能已在 %s 解决：%s（%.2f）→ /resolve %d\n", q.SuggestKey, q.SuggestItem, q.SuggestScore, q.ID))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func parseResolveArgs(input string) (int64, string, error) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return 0, "", fmt.Errorf("usage: /resolve <id> [note]")
+	}
+	var id int64
+	if _, err := fmt.Sscanf(strings.TrimPrefix(fields[0], "#"), "%d", &id); err != nil || id <= 0 {
+		return 0, "", fmt.Errorf("invalid question id %q", fields[0])
+	}
+	return id, strings.Join(fields[1:], " "), nil
+}
+
+/*
+========================
+Chat Context
+========================
+*/
+
+// relatedOpenQuestions：在 date 当时仍未解决、且与消息的余弦相似度 ≥ ChatOpenQuestionScore 的最老 n 条
+func relatedOpenQuestions(cfg Config, db *sql.DB, date, message string, n int) []OpenQuestion {
+	if n <= 0 || strings.TrimSpace(message) == "" {
+		return nil
+	}
+	qs, err := listOpenQuestions(db, true)
+	if err != nil {
+		return nil
+	}
+
+	var pending []OpenQuestion
+	for _, q := range qs {
+		if q.FirstDate > date {
+			continue
+		}
+		if q.Status == openQuestionResolved && q.ResolvedAt <= date {
+			continue
+		}
+		pending = append(pending, q)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// 一次请求：第 0 条是消息，其余是问题
+	model := activeEmbedModel(db, cfg)
+	prof := embedProfile(cfg, model)
+	inputs := make([]string, 0, len(pending)+1)
+	inputs = append(inputs, prof.QueryPrefix+message)
+	for _, q := range pending {
+		inputs = append(inputs, prof.DocumentPrefix+q.Text)
+	}
+	vecs, err := requestEmbeddings(model, inputs)
+	if err != nil || len(vecs) != len(inputs) {
+		return nil
+	}
+
+	var out []OpenQuestion
+	for i, q := range pending {
+		if cosineF32(vecs[0], vecs[i+1]) < cfg.ChatOpenQuestionScore {
+			continue
+		}
+		out = append(out, q)
+		if len(out) >= n {
+			break
+		}
+	}
+	return out
+}
+
+func formatOpenQuestionBlock(qs []OpenQuestion) string {
+	if len(qs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("用户长期悬而未决、且与当前话题相关的问题（如果这次对话有进展，可以自然地提一下）：\n")
+	for _, q := range qs {
+		b.WriteString(fmt.Sprintf("- %s（自 %s 起，出现在 %d 天）\n", q.Text, q.FirstDate, q.Seen))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+/*
+========================
+Fuzzy Text Similarity
+========================
+*/
+
+// textSimilarity：字符 bigram 的 Dice 系数（忽略大小写、空白与标点）
+func textSimilarity(a, b string) float64 {
+	ga, gb := charBigrams(a), charBigrams(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	inter := 0
+	for g, n := range ga {
+		inter += min(n, gb[g])
+	}
+	total := 0
+	for _, n := range ga {
+		total += n
+	}
+	for _, n := range gb {
+		total += n
+	}
+	return 2 * float64(inter) / float64(total)
+}
+
+func charBigrams(s string) map[string]int {
+	var rs []rune
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		rs = append(rs, r)
+	}
+	out := make(map[string]int)
+	for i := 0; i+1 < len(rs); i++ {
+		out[string(rs[i:i+2])]++
+	}
+	return out
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+========================
+Prompt Budget
+- 可用 = ChatContextTokens - ChatReserveTokens - 固定部分（系统事实、说明、本次输入）
+- 每个来源：优先级 + 最小 / 最大份额（占可用预算的比例）
+- 先按最大份额截断；仍超出时从低优先级开始压缩（先换成精简版本，再截断到最小份额），最后整块丢弃
+- 多轮历史（history）作为一个来源参与预算，从最老的一轮开始丢弃
+========================
+*/
+
+type BlockBudget struct {
+	Priority int     // 越大越重要，越晚被压缩
+	MinShare float64 // 压缩时至少保留的份额（0 = 可以整块丢弃）
+	MaxShare float64 // 任何情况下最多占用的份额
+}
+
+func defaultPromptBudgets() map[string]BlockBudget {
+	return map[string]BlockBudget{
+		"history":         {Priority: 80, MinShare: 0.10, MaxShare: 0.40},
+		"session_summary": {Priority: 75, MinShare: 0.05, MaxShare: 0.15},
+		"recent_raw":      {Priority: 70, MinShare: 0.05, MaxShare: 0.25},
+		"daily_summary":   {Priority: 60, MinShare: 0.10, MaxShare: 0.35},
+		"search_hit":      {Priority: 50, MinShare: 0.05, MaxShare: 0.30},
+		"open_questions":  {Priority: 40, MinShare: 0, MaxShare: 0.08},
+		"related_periods": {Priority: 30, MinShare: 0, MaxShare: 0.10},
+	}
+}
+
+// 未配置的来源：最低优先级，可以整块丢弃
+var fallbackBlockBudget = BlockBudget{Priority: 0, MinShare: 0, MaxShare: 0.20}
+
+type BudgetItem struct {
+	Source string `json:"source"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Action string `json:"action"` // kept | trimmed | summarized | dropped
+}
+
+type BudgetReport struct {
+	Available int          `json:"available"` // 分给上下文块与历史的 token
+	Fixed     int          `json:"fixed"`     // 系统事实 / 说明 / 本次输入
+	Used      int          `json:"used"`
+	Items     []BudgetItem `json:"items"`
+}
+
+// budgetEntry：一个参与预算的来源（一个 PromptBlock，或整段历史）
+type budgetEntry struct {
+	source  string
+	block   *PromptBlock
+	history []map[string]string
+	budget  BlockBudget
+	before  int
+	action  string
+}
+
+func (e *budgetEntry) tokens() int {
+	if e.block != nil {
+		return estimateTokens(e.block.Content)
+	}
+	n := 0
+	for _, m := range e.history {
+		n += estimateTokens(m["content"])
+	}
+	return n
+}
+
+// shrink：压缩到 limit 以内（limit ≤ 0 = 整块丢弃）
+func (e *budgetEntry) shrink(limit int) {
+	if e.tokens() <= limit {
+		return
+	}
+	if limit <= 0 {
+		e.block, e.history, e.action = nil, nil, "dropped"
+		return
+	}
+
+	if e.block == nil {
+		// 历史：成对丢弃最老的一轮，保持 user / assistant 交替
+		for len(e.history) >= 2 && e.tokens() > limit {
+			e.history = e.history[2:]
+		}
+		if len(e.history) < 2 {
+			e.history, e.action = nil, "dropped"
+		} else {
+			e.action = "trimmed"
+		}
+		return
+	}
+
+	// 有精简版本时先换成精简版本
+	if e.block.Summary != "" && e.action != "summarized" {
+		e.block.Content, e.block.Summary = e.block.Summary, ""
+		e.action = "summarized"
+		if e.tokens() <= limit {
+			return
+		}
+	}
+
+	// 最近对话保留结尾，其它保留开头
+	e.block.Content = truncateTokens(e.block.Content, limit, e.source == "recent_raw")
+	if e.action != "summarized" {
+		e.action = "trimmed"
+	}
+}
+
+// budgetChatPrompt：让上下文块与历史符合预算；fixed = 不参与压缩的部分的 token 数
+func budgetChatPrompt(cfg Config, fixed int, blocks []PromptBlock, history []map[string]string) ([]PromptBlock, []map[string]string, BudgetReport) {
+	rep := BudgetReport{Fixed: fixed}
+	rep.Available = max(cfg.ChatContextTokens-cfg.ChatReserveTokens-fixed, 0)
+
+	budgetFor := func(source string) BlockBudget {
+		if b, ok := cfg.PromptBudgets[source]; ok {
+			return b
+		}
+		return fallbackBlockBudget
+	}
+
+	entries := make([]*budgetEntry, 0, len(blocks)+1)
+	for i := range blocks {
+		b := blocks[i]
+		entries = append(entries, &budgetEntry{source: b.Source, block: &b, budget: budgetFor(b.Source), action: "kept"})
+	}
+	if len(history) > 0 {
+		entries = append(entries, &budgetEntry{source: "history", history: history, budget: budgetFor("history"), action: "kept"})
+	}
+	for _, e := range entries {
+		e.before = e.tokens()
+	}
+
+	share := func(f float64) int { return int(f * float64(rep.Available)) }
+	total := func() int {
+		n := 0
+		for _, e := range entries {
+			n += e.tokens()
+		}
+		return n
+	}
+
+	// 1️⃣ 最大份额
+	for _, e := range entries {
+		e.shrink(share(e.budget.MaxShare))
+	}
+
+	// 2️⃣ 低优先级先压缩到最小份额
+	order := make([]*budgetEntry, len(entries))
+	copy(order, entries)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].budget.Priority < order[j].budget.Priority })
+
+	for _, e := range order {
+		over := total() - rep.Available
+		if over <= 0 {
+			break
+		}
+		e.shrink(max(e.tokens()-over, share(e.budget.MinShare)))
+	}
+
+	// 3️⃣ 仍超出：从低优先级开始整块丢弃
+	for _, e := range order {
+		if total() <= rep.Available {
+			break
+		}
+		e.shrink(0)
+	}
+
+	var (
+		outBlocks  []PromptBlock
+		outHistory []map[string]string
+	)
+	for _, e := range entries {
+		after := e.tokens()
+		rep.Used += after
+		rep.Items = append(rep.Items, BudgetItem{Source: e.source, Before: e.before, After: after, Action: e.action})
+		switch {
+		case e.block != nil:
+			outBlocks = append(outBlocks, *e.block)
+		case e.history != nil:
+			outHistory = e.history
+		}
+	}
+	return outBlocks, outHistory, rep
+}
+
+// truncateTokens：按行截断到 limit 以内；keepTail = 保留结尾
+func truncateTokens(s string, limit int, keepTail bool) string {
+	const marker = "……（已截断）"
+	if estimateTokens(s) <= limit {
+		return s
+	}
+	limit -= estimateTokens(marker)
+	if limit <= 0 {
+		return ""
+	}
+
+	lines := strings.Split(s, "\n")
+	var kept []string
+	used := 0
+	for i := range lines {
+		line := lines[i]
+		if keepTail {
+			line = lines[len(lines)-1-i]
+		}
+		n := estimateTokens(line) + 1
+		if used+n > limit {
+			// 单行过长：截取该行的一部分
+			if rest := limit - used; rest > 0 && len(kept) == 0 {
+				rs := []rune(line)
+				for len(rs) > 0 && estimateTokens(string(rs)) > rest {
+					if keepTail {
+						rs = rs[len(rs)/4+1:]
+					} else {
+						rs = rs[:len(rs)*3/4]
+					}
+				}
+				kept = append(kept, string(rs))
+			}
+			break
+		}
+		kept = append(kept, line)
+		used += n
+	}
+
+	if keepTail {
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+		return marker + "\n" + strings.Join(kept, "\n")
+	}
+	return strings.Join(kept, "\n") + "\n" + marker
+}
+
+func formatBudgetReport(rep BudgetReport) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("available %d tokens（fixed %d），used %d\n", rep.Available, rep.Fixed, rep.Used))
+	for _, it := range rep.Items {
+		b.WriteString(fmt.Sprintf("  %-16s %6d → %-6d %s\n", it.Source, it.Before, it.After, it.Action))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+// han：n 个汉字，estimateTokens 恰好为 n
+func han(n int) string {
+	return strings.Repeat("字", n)
+}
+
+// hanLines：lines 行、每行 width 个汉字
+func hanLines(lines, width int) string {
+	out := make([]string, lines)
+	for i := range out {
+		out[i] = han(width)
+	}
+	return strings.Join(out, "\n")
+}
+
+func budgetTestConfig(context, reserve int) Config {
+	return Config{
+		ChatContextTokens: context,
+		ChatReserveTokens: reserve,
+		PromptBudgets:     defaultPromptBudgets(),
+	}
+}
+
+func budgetItem(t *testing.T, rep BudgetReport, source string) BudgetItem {
+	t.Helper()
+	for _, it := range rep.Items {
+		if it.Source == source {
+			return it
+		}
+	}
+	t.Fatalf("no budget item for %s in %+v", source, rep.Items)
+	return BudgetItem{}
+}
+
+func TestBudgetChatPromptKeepsWhatFits(t *testing.T) {
+	blocks := []PromptBlock{
+		{Source: "daily_summary", Content: han(100)},
+		{Source: "search_hit", Content: han(100)},
+	}
+	history := []map[string]string{
+		{"role": "user", "content": han(50)},
+		{"role": "assistant", "content": han(50)},
+	}
+
+	out, hist, rep := budgetChatPrompt(budgetTestConfig(1200, 100), 100, blocks, history)
+
+	if rep.Available != 1000 || rep.Fixed != 100 || rep.Used != 300 {
+		t.Fatalf("report = %+v, want available 1000, fixed 100, used 300", rep)
+	}
+	if len(out) != 2 || len(hist) != 2 {
+		t.Fatalf("got %d blocks / %d history messages, want 2 / 2", len(out), len(hist))
+	}
+	for _, it := range rep.Items {
+		if it.Action != "kept" || it.Before != it.After {
+			t.Errorf("%s: %+v, want kept unchanged", it.Source, it)
+		}
+	}
+}
+
+func TestBudgetChatPromptPerSourceMaxShare(t *testing.T) {
+	// 可用 1000：search_hit 最多 30%，daily_summary 最多 35%，history 最多 40%
+	blocks := []PromptBlock{
+		{Source: "daily_summary", Content: han(500), Summary: han(120)},
+		{Source: "search_hit", Content: hanLines(50, 10)},
+		{Source: "open_questions", Content: han(20)},
+	}
+	var history []map[string]string
+	for i := 0; i < 3; i++ {
+		history = append(history,
+			map[string]string{"role": "user", "content": han(100)},
+			map[string]string{"role": "assistant", "content": han(100)},
+		)
+	}
+	last := history[len(history)-1]["content"]
+
+	out, hist, rep := budgetChatPrompt(budgetTestConfig(1000, 0), 0, blocks, history)
+
+	// 有精简版本：先换成精简版本
+	daily := budgetItem(t, rep, "daily_summary")
+	if daily.Action != "summarized" || daily.After != 120 {
+		t.Errorf("daily_summary = %+v, want summarized to 120", daily)
+	}
+	if out[0].Content != han(120) {
+		t.Errorf("daily_summary content was not replaced by its summary")
+	}
+
+	// 没有精简版本：按行截断到 300 以内
+	hit := budgetItem(t, rep, "search_hit")
+	if hit.Action != "trimmed" || hit.After > 300 || hit.After == 0 {
+		t.Errorf("search_hit = %+v, want trimmed to at most 300", hit)
+	}
+	if !strings.HasSuffix(out[1].Content, "……（已截断）") {
+		t.Errorf("trimmed search_hit should end with the truncation marker")
+	}
+
+	// 小块不受影响
+	if open := budgetItem(t, rep, "open_questions"); open.Action != "kept" {
+		t.Errorf("open_questions = %+v, want kept", open)
+	}
+
+	// 历史：成对丢弃最老的一轮，保留最近的回答
+	h := budgetItem(t, rep, "history")
+	if h.Action != "trimmed" || h.After > 400 {
+		t.Errorf("history = %+v, want trimmed to at most 400", h)
+	}
+	if len(hist) != 4 || hist[0]["role"] != "user" || hist[len(hist)-1]["content"] != last {
+		t.Errorf("history kept %d messages starting with %s, want the latest 2 turns", len(hist), hist[0]["role"])
+	}
+
+	if rep.Used > rep.Available {
+		t.Errorf("used %d > available %d", rep.Used, rep.Available)
+	}
+}
+
+func TestBudgetChatPromptDropsLowPriorityFirst(t *testing.T) {
+	// 每块都在自己的最大份额内，但合计 1380 > 1000
+	blocks := []PromptBlock{
+		{Source: "daily_summary", Content: han(350)},
+		{Source: "search_hit", Content: han(300)},
+		{Source: "recent_raw", Content: han(150)},
+		{Source: "related_periods", Content: han(100)},
+		{Source: "open_questions", Content: han(80)},
+	}
+	history := []map[string]string{
+		{"role": "user", "content": han(200)},
+		{"role": "assistant", "content": han(200)},
+	}
+
+	_, hist, rep := budgetChatPrompt(budgetTestConfig(1000, 0), 0, blocks, history)
+
+	for _, source := range []string{"related_periods", "open_questions"} {
+		if it := budgetItem(t, rep, source); it.Action != "dropped" || it.After != 0 {
+			t.Errorf("%s = %+v, want dropped", source, it)
+		}
+	}
+	if hit := budgetItem(t, rep, "search_hit"); hit.Action != "trimmed" || hit.After > 100 || hit.After < 50 {
+		t.Errorf("search_hit = %+v, want trimmed to between its min share (50) and 100", hit)
+	}
+	for _, source := range []string{"daily_summary", "recent_raw", "history"} {
+		if it := budgetItem(t, rep, source); it.Action != "kept" {
+			t.Errorf("%s = %+v, want kept", source, it)
+		}
+	}
+	if len(hist) != 2 {
+		t.Errorf("history has %d messages, want 2", len(hist))
+	}
+	if rep.Used > rep.Available {
+		t.Errorf("used %d > available %d", rep.Used, rep.Available)
+	}
+}
+
+func TestBudgetChatPromptNoBudget(t *testing.T) {
+	blocks := []PromptBlock{
+		{Source: "daily_summary", Content: han(10), Summary: han(5)},
+		{Source: "search_hit", Content: han(10)},
+	}
+	history := []map[string]string{
+		{"role": "user", "content": han(10)},
+		{"role": "assistant", "content": han(10)},
+	}
+
+	tests := []struct {
+		name             string
+		context, reserve int
+		fixed            int
+	}{
+		{"zero", 500, 500, 0},
+		{"reserve larger than context", 500, 800, 0},
+		{"fixed part larger than context", 500, 0, 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, hist, rep := budgetChatPrompt(budgetTestConfig(tt.context, tt.reserve), tt.fixed, blocks, history)
+			if rep.Available != 0 || rep.Used != 0 {
+				t.Errorf("report = %+v, want available 0, used 0", rep)
+			}
+			if len(out) != 0 || len(hist) != 0 {
+				t.Errorf("got %d blocks / %d history messages, want none", len(out), len(hist))
+			}
+			for _, it := range rep.Items {
+				if it.Action != "dropped" {
+					t.Errorf("%s: %+v, want dropped", it.Source, it)
+				}
+			}
+		})
+	}
+}
+
+func TestBudgetChatPromptSingleOversizedBlock(t *testing.T) {
+	// 只有一块且远超自己的份额：截断到最大份额，而不是整块丢弃
+	tail := "最后一行" + han(6)
+	content := hanLines(200, 10) + "\n" + tail
+
+	out, _, rep := budgetChatPrompt(budgetTestConfig(1000, 0), 0, []PromptBlock{
+		{Source: "recent_raw", Content: content},
+	}, nil)
+
+	it := budgetItem(t, rep, "recent_raw")
+	if it.Action != "trimmed" || it.After > 250 || it.After == 0 {
+		t.Fatalf("recent_raw = %+v, want trimmed to at most 250", it)
+	}
+	// 最近对话保留结尾
+	if !strings.HasPrefix(out[0].Content, "……（已截断）") || !strings.HasSuffix(out[0].Content, tail) {
+		t.Errorf("recent_raw should keep its last lines behind the truncation marker")
+	}
+
+	// 单行超长：截取该行的一部分
+	out, _, rep = budgetChatPrompt(budgetTestConfig(1000, 0), 0, []PromptBlock{
+		{Source: "search_hit", Content: han(2000)},
+	}, nil)
+	if it := budgetItem(t, rep, "search_hit"); it.Action != "trimmed" || it.After > 300 || it.After == 0 {
+		t.Errorf("search_hit = %+v, want trimmed to at most 300", it)
+	}
+	if !strings.HasPrefix(out[0].Content, "字") {
+		t.Errorf("single long line should keep its beginning")
+	}
+}
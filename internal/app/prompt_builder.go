@@ -0,0 +1,165 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Prompt Builder
+- Chat 与 /debug 共用同一份 prompt 组装：系统事实 → 说明 → 上下文块（预算后）→ 历史 → 本次输入
+- 结果是结构化的 ChatPrompt（每段的来源 / 角色 / token 数 + 最终 messages 数组）
+- /debug --save 保存为 JSON，/replay 原样重发给模型
+========================
+*/
+
+const chatSystemHeader = "以下是用户的对话历史与已知事实，请严格基于这些信息回答。\n\n"
+
+type PromptPart struct {
+	Source  string `json:"source"` // system_facts | header | PromptBlock.Source
+	Role    string `json:"role"`
+	Tokens  int    `json:"tokens"`
+	Content string `json:"content"`
+}
+
+type ChatPrompt struct {
+	Date      string              `json:"date"`
+	Simulated bool                `json:"simulated"`
+	Input     string              `json:"input"`
+	Parts     []PromptPart        `json:"parts"`   // system prompt 的组成
+	History   []map[string]string `json:"history"` // 位于 system 与本次输入之间的历史消息
+	Messages  []map[string]string `json:"messages"`
+	Tokens    int                 `json:"tokens"`
+	Budget    BudgetReport        `json:"budget"`
+	CreatedAt string              `json:"created_at"`
+}
+
+type PromptBuilder struct {
+	cfg Config
+	db  *sql.DB
+}
+
+func NewPromptBuilder(cfg Config, db *sql.DB) *PromptBuilder {
+	return &PromptBuilder{cfg: cfg, db: db}
+}
+
+// Build：必须在写入本次 user 输入之前调用（历史与 recent_raw 都来自当天日志）
+func (pb *PromptBuilder) Build(input string, asOf time.Time) ChatPrompt {
+	now := clockAt(pb.cfg, asOf)
+	date := now.Format("2006-01-02")
+	simulated := !asOf.IsZero()
+
+	blocks := BuildChatContext(pb.cfg, pb.db, date, input, asOf)
+	history := chatHistoryFor(pb.cfg, pb.db, date, simulated)
+
+	facts := systemFacts(now, simulated)
+	fixed := estimateTokens(facts + chatSystemHeader + input)
+	blocks, history, budget := budgetChatPrompt(pb.cfg, fixed, blocks, history)
+
+	p := ChatPrompt{
+		Date:      date,
+		Simulated: simulated,
+		Input:     input,
+		History:   history,
+		Budget:    budget,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+
+	p.addPart("system_facts", "system", facts)
+	p.addPart("header", "system", chatSystemHeader)
+	for _, b := range blocks {
+		p.addPart(b.Source, b.Role, b.Content+"\n\n")
+	}
+
+	var system strings.Builder
+	for _, part := range p.Parts {
+		system.WriteString(part.Content)
+	}
+	p.Messages = chatMessages(system.String(), history, input)
+	for _, m := range p.Messages {
+		p.Tokens += estimateTokens(m["content"])
+	}
+	return p
+}
+
+func (p *ChatPrompt) addPart(source, role, content string) {
+	p.Parts = append(p.Parts, PromptPart{
+		Source:  source,
+		Role:    role,
+		Tokens:  estimateTokens(content),
+		Content: content,
+	})
+}
+
+// formatChatPrompt：/debug 的展示（每段带 token 数）
+func formatChatPrompt(p ChatPrompt) string {
+	var b strings.Builder
+
+	b.WriteString("【User Input】\n")
+	b.WriteString(p.Input + "\n\n")
+
+	b.WriteString("【System Prompt（将以 system role 发送给模型）】\n")
+	for _, part := range p.Parts {
+		b.WriteString(fmt.Sprintf("---- %s | %s | %d tokens ----\n", part.Source, part.Role, part.Tokens))
+		b.WriteString(strings.TrimRight(part.Content, "\n") + "\n\n")
+	}
+
+	b.WriteString(fmt.Sprintf("【History（%d 条消息，位于 system 与本次输入之间）】\n", len(p.History)))
+	for _, m := range p.History {
+		b.WriteString(fmt.Sprintf("---- %s | %d tokens ----\n", m["role"], estimateTokens(m["content"])))
+		b.WriteString(m["content"] + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString("【Prompt Budget】\n")
+	b.WriteString(formatBudgetReport(p.Budget) + "\n\n")
+
+	b.WriteString(fmt.Sprintf("【Messages】%d messages, ~%d tokens\n", len(p.Messages), p.Tokens))
+	return b.String()
+}
+
+/*
+========================
+Save / Replay
+========================
+*/
+
+func saveChatPrompt(path string, p ChatPrompt) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func loadChatPrompt(path string) (ChatPrompt, error) {
+	var p ChatPrompt
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("invalid prompt file %s: %w", path, err)
+	}
+	if len(p.Messages) == 0 {
+		return p, fmt.Errorf("prompt file %s has no messages", path)
+	}
+	return p, nil
+}
+
+// ReplayPrompt：/replay file.json —— 把抓取的 messages 原样重发（不写入日志）
+func ReplayPrompt(path string) error {
+	p, err := loadChatPrompt(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("▶ replay %s：%d messages, ~%d tokens（captured %s）\n\n", path, len(p.Messages), p.Tokens, p.CreatedAt)
+	streamChatMessages(p.Messages)
+	fmt.Println()
+	return nil
+}
@@ -43,7 +43,8 @@ OUTPUT FORMAT (JSON only, no markdown, no extra fields):
   "lowlights": []
 }
 
-RAW CONVERSATION LOG (JSONL):
+RAW CONVERSATION LOG (JSONL, lines of the same chat session are kept together; the "session" field names
+the session of each line as "#id「title」"; lines with role "session_summary" summarize earlier turns of that session):
 {{TRANSCRIPT}}
 `
 
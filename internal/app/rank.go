@@ -0,0 +1,192 @@
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Ranking (time decay + type boost + MMR)
+- relevance = cosine × recency × type boost
+- MMR 重排：保证 topK 覆盖不同时期，而不是同一周的五条 daily
+========================
+*/
+
+// 宽泛问题的关键词：命中时额外偏好 weekly / monthly
+var broadQueryHints = []string{
+	"总体", "整体", "总的来说", "这段时间", "一直以来", "趋势", "变化", "演变", "长期",
+	"overall", "in general", "trend", "over time", "evolve", "long term", "big picture",
+}
+
+func isBroadQuery(q string) bool {
+	q = strings.ToLower(q)
+	for _, h := range broadQueryHints {
+		if strings.Contains(q, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// recencyFactor：按 end_date 计算时间衰减（半衰期模型）
+// weight=0 或 halfLife<=0 时不衰减
+func recencyFactor(cfg Config, endDate string, now time.Time) float64 {
+	if cfg.SearchRecencyWeight <= 0 || cfg.SearchRecencyHalfLife <= 0 {
+		return 1
+	}
+	end, err := time.ParseInLocation("2006-01-02", endDate, cfg.Location)
+	if err != nil {
+		return 1
+	}
+
+	age := now.Sub(end).Hours() / 24
+	if age < 0 {
+		age = 0
+	}
+
+	w := math.Min(cfg.SearchRecencyWeight, 1)
+	return (1 - w) + w*math.Pow(0.5, age/cfg.SearchRecencyHalfLife)
+}
+
+// typeBoost：按 summary 类型加权；宽泛问题叠加 SearchBroadTypeBoost
+func typeBoost(cfg Config, typ string, broad bool) float64 {
+	b := 1.0
+	if v, ok := cfg.SearchTypeBoost[typ]; ok && v > 0 {
+		b *= v
+	}
+	if broad {
+		if v, ok := cfg.SearchBroadTypeBoost[typ]; ok && v > 0 {
+			b *= v
+		}
+	}
+	return b
+}
+
+// rerankMMR：Maximal Marginal Relevance
+// mmr = λ·relevance − (1−λ)·max(sim(candidate, selected))
+// 输入需已按 Score 降序；λ>=1 时等价于直接截断
+func rerankMMR(hits []SearchHit, k int, lambda float64) []SearchHit {
+	if k <= 0 || len(hits) == 0 {
+		return nil
+	}
+	if lambda >= 1 || len(hits) <= 1 {
+		for i := range hits {
+			hits[i].MMR = hits[i].Score
+		}
+		if len(hits) > k {
+			hits = hits[:k]
+		}
+		return hits
+	}
+
+	selected := make([]SearchHit, 0, k)
+	used := make([]bool, len(hits))
+
+	for len(selected) < k {
+		best, bestScore, bestSim := -1, math.Inf(-1), 0.0
+
+		for i, h := range hits {
+			if used[i] {
+				continue
+			}
+
+			maxSim := 0.0
+			for _, s := range selected {
+				if sim := cosineF32(h.vec, s.vec); sim > maxSim {
+					maxSim = sim
+				}
+			}
+
+			mmr := lambda*h.Score - (1-lambda)*maxSim
+			if mmr > bestScore {
+				best, bestScore, bestSim = i, mmr, maxSim
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		h := hits[best]
+		h.MMR = bestScore
+		h.Redundancy = bestSim
+		selected = append(selected, h)
+	}
+
+	return selected
+}
+
+// formatMatch：命中的是哪个字段的哪一条
+func formatMatch(h SearchHit) string {
+	if h.MatchField == "" {
+		return ""
+	}
+	return fmt.Sprintf("↳ matched %s: %s", h.MatchField, h.MatchItem)
+}
+
+// formatExplain：/search --explain 的分数拆解
+func formatExplain(h SearchHit) string {
+	s := fmt.Sprintf(
+		"cos=%.3f × recency=%.3f × type=%.2f → %.3f | mmr=%.3f (redundancy %.2f)",
+		h.Cosine, h.Recency, h.Boost, h.Score, h.MMR, h.Redundancy,
+	)
+	if h.MatchField != "" {
+		s += fmt.Sprintf(" | best item=%.3f", h.MatchScore)
+	} else if h.Chunk > 0 {
+		s += fmt.Sprintf(" | chunk=%d", h.Chunk)
+	}
+	if h.Reranked {
+		s += fmt.Sprintf(" | rerank=%.3f", h.Rerank)
+	}
+	return s
+}
+
+/*
+========================
+Vector Helpers
+========================
+*/
+
+// decodeVec：把 float32 little-endian blob 解码为向量；长度不足返回 ok=false
+func decodeVec(blob []byte, dim int) ([]float32, bool) {
+	if dim <= 0 || len(blob) < dim*4 {
+		return nil, false
+	}
+	v := make([]float32, dim)
+	for i := 0; i < dim; i++ {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+	}
+	return v, true
+}
+
+func dotF32(a, b []float32) float64 {
+	n := min(len(a), len(b))
+	var s float64
+	for i := 0; i < n; i++ {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
+
+func cosineF32(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	na, nb := l2norm(a), l2norm(b)
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dotF32(a, b) / (na * nb)
+}
+
+// sortHitsByScore：按 Score 降序（稳定排序，分数相同保持原顺序）
+func sortHitsByScore(hits []SearchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+}
@@ -0,0 +1,305 @@
+package app
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Recall Session
+========================
+*/
+
+// RecallSession：/recall 多轮问答会话
+// - Turns：本次会话的问答历史（用于改写追问）
+// - Memory：会话内累积的检索结果（按 summary 去重，后续轮次可沿用）
+// - AsOf：--as-of 模拟日期（零值 = 现在），整个会话只使用这一天之前的记忆
+type RecallSession struct {
+	ID     string
+	AsOf   time.Time
+	Turns  []RecallTurn
+	Memory []SearchHit
+}
+
+type RecallTurn struct {
+	Question string // 用户原话
+	Query    string // 改写后的独立问题（送入检索）
+	Answer   string
+	Refs     []string // 本轮提供给模型的记忆（type key）
+}
+
+func newRecallSession(cfg Config, asOf time.Time) *RecallSession {
+	return &RecallSession{
+		ID:   time.Now().In(cfg.Location).Format("20060102-150405"),
+		AsOf: asOf,
+	}
+}
+
+// RunRecall：进入多轮问答；first 非空时作为第一轮问题
+func RunRecall(lw *LogWriter, cfg Config, db *sql.DB, reader *bufio.Reader, first string, asOf time.Time) {
+	s := newRecallSession(cfg, asOf)
+	fmt.Printf("🔁 recall session %s（追问会沿用上下文；/memory 查看已检索的记忆，/end 退出）\n", s.ID)
+	if !asOf.IsZero() {
+		fmt.Printf("⏪ as of %s：只使用这一天之前的记忆\n", asOf.Format("2006-01-02"))
+	}
+
+	q := strings.TrimSpace(first)
+	for {
+		if q == "" {
+			fmt.Print("\nRecall> ")
+			line, err := readLine(reader)
+			if err != nil {
+				return
+			}
+			q = strings.TrimSpace(sanitizeUTF8(line))
+		}
+
+		switch q {
+		case "":
+			continue
+		case "/end", "exit":
+			fmt.Printf("recall session %s ended (%d turns)\n", s.ID, len(s.Turns))
+			return
+		case "/memory":
+			if len(s.Memory) == 0 {
+				fmt.Println("no memory retrieved yet")
+			}
+			for i, h := range s.Memory {
+				fmt.Println(formatRefLine(i+1, h))
+			}
+			q = ""
+			continue
+		}
+
+		fmt.Println("\nAssistant>")
+		if err := s.ask(lw, cfg, db, q); err != nil {
+			fmt.Println("recall error:", err)
+		}
+		q = ""
+	}
+}
+
+// ask：改写 → 检索 → 合并沿用的旧记忆 → 流式回答 → 记录
+func (s *RecallSession) ask(lw *LogWriter, cfg Config, db *sql.DB, question string) error {
+	// 1. 追问 → 独立问题
+	standalone := s.rewrite(cfg, question)
+	if standalone != question {
+		fmt.Println("↪ " + standalone)
+	}
+
+	// 2. 检索（时间表达仍然生效；--as-of 只用之前的记忆）
+	query, opts := scopeQuery(cfg, standalone, s.AsOf)
+	hits, err := RetrieveMemories(db, cfg, query, opts)
+	if err != nil {
+		return err
+	}
+	hits = truncateHits(hits, cfg.SearchTopK)
+
+	// 3. 沿用之前命中、且与当前问题仍相关的记忆
+	hits = append(hits, s.reuse(cfg, db, query, opts.Scope, hits)...)
+	s.remember(hits)
+	hits = append(hits, drillDown(cfg, hits, query)...)
+
+	if len(hits) == 0 {
+		fmt.Println(askNoMemoryAnswer)
+		writeAskRecords(lw, question, askNoMemoryAnswer, nil)
+		s.record(cfg, RecallTurn{Question: question, Query: standalone, Answer: askNoMemoryAnswer, Refs: []string{}})
+		return nil
+	}
+
+	// 4. 回答（带会话历史）
+	prompt := askPrompt(hits, opts, s.history(cfg.RecallHistoryTurns), standalone)
+	answer, check, err := streamAnswer(prompt, len(hits))
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatCitationFooter(hits, check))
+	Speak(stripCitations(answer))
+	writeAskRecords(lw, question, answer, citedRefs(hits, check))
+
+	refs := make([]string, 0, len(hits))
+	for _, h := range hits {
+		refs = append(refs, hitLabel(h)+" "+h.Date)
+	}
+	s.record(cfg, RecallTurn{Question: question, Query: standalone, Answer: answer, Refs: refs})
+	return nil
+}
+
+/*
+========================
+Follow-up Rewrite
+========================
+*/
+
+// rewrite：把依赖上下文的追问改写为可独立检索的问题；失败时退回原问题
+func (s *RecallSession) rewrite(cfg Config, question string) string {
+	if len(s.Turns) == 0 {
+		return question
+	}
+
+	out, err := callLLMNonStream(buildRecallRewritePrompt(s.history(cfg.RecallHistoryTurns), question))
+	if err != nil {
+		fmt.Println("[warn] rewrite failed:", err)
+		return question
+	}
+
+	out = strings.TrimSpace(firstLine(out))
+	out = strings.Trim(out, "\"'“”「」")
+	if out == "" {
+		return question
+	}
+	return out
+}
+
+// history：最近 n 轮问答（回答截断，避免 prompt 过长）
+func (s *RecallSession) history(n int) string {
+	turns := s.Turns
+	if n > 0 && len(turns) > n {
+		turns = turns[len(turns)-n:]
+	}
+
+	var b strings.Builder
+	for _, t := range turns {
+		b.WriteString("问：" + t.Query + "\n")
+		b.WriteString("答：" + truncateRunes(stripCitations(t.Answer), 300) + "\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func buildRecallRewritePrompt(history, question string) string {
+	return fmt.Sprintf(`
+你负责把对话中的追问改写成一个“可以独立理解、用于检索用户历史记录”的问题。
+
+【之前的问答】
+%s
+
+【新的追问】
+%s
+
+【要求】
+- 补全追问中省略的主语、对象和时间（例如“后来呢”“那个项目”）
+- 保留原问题中的时间表达（例如“上个月”“2025年3月”）
+- 如果追问本身已经完整，原样输出
+- 只输出改写后的问题，一行，不要解释
+`, history, question)
+}
+
+/*
+========================
+Memory Reuse
+========================
+*/
+
+// reuse：会话中之前命中、本轮未命中，但与当前问题相似度仍够高的记忆
+func (s *RecallSession) reuse(cfg Config, db *sql.DB, query string, scope *TimeScope, fresh []SearchHit) []SearchHit {
+	if len(s.Memory) == 0 || cfg.RecallReuseMax <= 0 || strings.TrimSpace(query) == "" {
+		return nil
+	}
+
+	seen := make(map[int64]bool, len(fresh))
+	for _, h := range fresh {
+		seen[h.ID] = true
+	}
+
+	qv, qn, err := embedText(cfg, activeEmbedModel(db, cfg), query)
+	if err != nil || qn == 0 {
+		return nil
+	}
+
+	var out []SearchHit
+	for _, h := range s.Memory {
+		if seen[h.ID] || len(h.vec) != len(qv) {
+			continue
+		}
+		if scope != nil && !scope.overlaps(h.StartDate, h.EndDate) {
+			continue
+		}
+		vn := l2norm(h.vec)
+		if vn == 0 {
+			continue
+		}
+		cos := dotF32(qv, h.vec) / (qn * vn)
+		if math.IsNaN(cos) || cos < cfg.RecallReuseMinScore {
+			continue
+		}
+		h.Score = cos
+		out = append(out, h)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if len(out) > cfg.RecallReuseMax {
+		out = out[:cfg.RecallReuseMax]
+	}
+	return out
+}
+
+// remember：把本轮记忆并入会话记忆集（按 summary id 去重）
+func (s *RecallSession) remember(hits []SearchHit) {
+	seen := make(map[int64]bool, len(s.Memory))
+	for _, h := range s.Memory {
+		seen[h.ID] = true
+	}
+	for _, h := range hits {
+		if !seen[h.ID] {
+			seen[h.ID] = true
+			s.Memory = append(s.Memory, h)
+		}
+	}
+}
+
+/*
+========================
+Session Log (JSONL)
+========================
+*/
+
+type recallRecord struct {
+	Session  string   `json:"session"`
+	Turn     int      `json:"turn"`
+	Time     string   `json:"time"`
+	Question string   `json:"question"`
+	Query    string   `json:"query"`
+	Answer   string   `json:"answer"`
+	Refs     []string `json:"refs"`
+}
+
+// record：追加本轮问答，并写入 RecallDir/<date>.jsonl（改写后的检索词与全部 refs；问答本身另以 kind = ask 写入 raw log）
+func (s *RecallSession) record(cfg Config, t RecallTurn) {
+	s.Turns = append(s.Turns, t)
+
+	now := time.Now().In(cfg.Location)
+	b, err := json.Marshal(recallRecord{
+		Session:  s.ID,
+		Turn:     len(s.Turns),
+		Time:     now.Format(time.RFC3339),
+		Question: sanitizeUTF8(t.Question),
+		Query:    sanitizeUTF8(t.Query),
+		Answer:   sanitizeUTF8(t.Answer),
+		Refs:     t.Refs,
+	})
+	if err != nil {
+		return
+	}
+
+	_ = os.MkdirAll(cfg.RecallDir, 0755)
+	f, err := os.OpenFile(
+		filepath.Join(cfg.RecallDir, now.Format("2006-01-02")+".jsonl"),
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0644,
+	)
+	if err != nil {
+		fmt.Println("[warn] recall log failed:", err)
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(b, '\n'))
+}
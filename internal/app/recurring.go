@@ -0,0 +1,400 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Recurring Questions (/recurring)
+- 每个 daily 生成后，把 open_questions 与当天的用户提问写入 question_log（带向量）
+- /recurring：在时间窗口内对问题聚类，报告出现在多个不同日子的问题簇
+- 之后的 summary 条目（highlights / progress / wins ...）与问题足够相似时，标记为“可能已解决”
+========================
+*/
+
+const (
+	questionSourceOpen = "open_question" // daily JSON 的 open_questions
+	questionSourceUser = "user"          // 当天原始对话中的用户提问
+)
+
+// 可能回答了问题的 summary 字段
+var resolvingFields = []string{"highlights", "progress", "notable_decisions", "wins", "systems_improvements"}
+
+type loggedQuestion struct {
+	Date   string
+	Source string
+	Text   string
+	vec    []float32
+}
+
+type QuestionCluster struct {
+	Questions []loggedQuestion
+	Days      []string // 出现过的不同日期（升序）
+	Rep       string   // 最接近簇中心的问题
+
+	ResolvedType  string // 可能解决它的 summary（空 = 未发现）
+	ResolvedKey   string
+	ResolvedItem  string
+	ResolvedScore float64
+
+	centroid []float32
+}
+
+func (c *QuestionCluster) FirstSeen() string { return c.Days[0] }
+func (c *QuestionCluster) LastSeen() string  { return c.Days[len(c.Days)-1] }
+
+/*
+========================
+Question Log (job)
+========================
+*/
+
+// logDailyQuestions：重新生成 daily 时先清掉当天的旧记录
+func logDailyQuestions(cfg Config, db *sql.DB, date string) error {
+	model := activeEmbedModel(db, cfg)
+	if _, err := db.Exec(`DELETE FROM question_log WHERE date=? AND model=?`, date, model); err != nil {
+		return err
+	}
+	return insertDailyQuestions(cfg, db, date, model)
+}
+
+func insertDailyQuestions(cfg Config, db *sql.DB, date, model string) error {
+	qs := extractDailyQuestions(cfg, db, date)
+	if len(qs) == 0 {
+		return nil
+	}
+
+	prefix := embedProfile(cfg, model).QueryPrefix
+	inputs := make([]string, len(qs))
+	for i, q := range qs {
+		inputs[i] = prefix + q.Text
+	}
+	vecs, err := requestEmbeddings(model, inputs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format(time.RFC3339)
+	for i, q := range qs {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO question_log(date, source, text, model, dim, vec, l2, created_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+		`, date, q.Source, q.Text, model, len(vecs[i]), encodeVec(vecs[i]), l2norm(vecs[i]), now); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// extractDailyQuestions：daily 的 open_questions + 当天原始对话中看起来是提问的用户消息
+func extractDailyQuestions(cfg Config, db *sql.DB, date string) []loggedQuestion {
+	seen := make(map[string]bool)
+	var out []loggedQuestion
+	add := func(source, text string) {
+		text = truncateRunes(strings.TrimSpace(text), 300)
+		if runeLen(text) < 4 || seen[text] {
+			return
+		}
+		seen[text] = true
+		out = append(out, loggedQuestion{Date: date, Source: source, Text: text})
+	}
+
+	if js, ok := loadSummaryJSON(db, "daily", date); ok {
+		var obj map[string]any
+		if json.Unmarshal([]byte(js), &obj) == nil {
+			if list, ok := obj["open_questions"].([]any); ok {
+				for _, it := range list {
+					add(questionSourceOpen, itemText(it))
+				}
+			}
+		}
+	}
+
+	if lines, _, err := loadRawTranscript(cfg, date); err == nil {
+		for _, l := range lines {
+			if l.Role == "user" && looksLikeQuestion(l.Content) {
+				add(questionSourceUser, l.Content)
+			}
+		}
+	}
+	return out
+}
+
+var questionHints = []string{"为什么", "怎么", "如何", "是否", "是不是", "能不能", "要不要", "该不该", "哪个", "什么"}
+var questionPrefixes = []string{"how ", "why ", "what ", "which ", "should ", "can ", "could ", "is ", "are ", "does ", "do "}
+
+func looksLikeQuestion(s string) bool {
+	s = strings.TrimSpace(s)
+	if n := runeLen(s); n < 6 || n > 300 || strings.HasPrefix(s, "/") {
+		return false
+	}
+	if strings.HasSuffix(s, "?") || strings.HasSuffix(s, "？") {
+		return true
+	}
+	for _, h := range questionHints {
+		if strings.Contains(s, h) {
+			return true
+		}
+	}
+	lower := strings.ToLower(s)
+	for _, p := range questionPrefixes {
+		if strings.HasPrefix(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// backfillQuestions：窗口内还没有记录的 daily 补做一次（当天没有问题的 daily 每次都会重新检查，但不会调用 embedding）
+func backfillQuestions(cfg Config, db *sql.DB, model, from string) error {
+	rows, err := db.Query(`
+		SELECT period_key FROM summaries
+		WHERE type='daily' AND period_key >= ?
+		  AND period_key NOT IN (SELECT DISTINCT date FROM question_log WHERE model=?)
+		ORDER BY period_key
+	`, from, model)
+	if err != nil {
+		return err
+	}
+	var dates []string
+	for rows.Next() {
+		var d string
+		if rows.Scan(&d) == nil {
+			dates = append(dates, d)
+		}
+	}
+	rows.Close()
+
+	for _, d := range dates {
+		if err := insertDailyQuestions(cfg, db, d, model); err != nil {
+			return fmt.Errorf("question log %s: %w", d, err)
+		}
+	}
+	return nil
+}
+
+/*
+========================
+Clustering
+========================
+*/
+
+// FindRecurring：窗口内出现在至少 RecurringMinDays 个不同日子的问题簇（按天数降序）
+func FindRecurring(db *sql.DB, cfg Config, days int) ([]*QuestionCluster, error) {
+	model := activeEmbedModel(db, cfg)
+	from := time.Now().In(cfg.Location).AddDate(0, 0, -days).Format("2006-01-02")
+
+	if err := backfillQuestions(cfg, db, model, from); err != nil {
+		return nil, err
+	}
+
+	qs, err := loadLoggedQuestions(db, model, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*QuestionCluster
+	for _, c := range clusterQuestions(qs, cfg.RecurringSimilarity) {
+		if len(c.Days) >= cfg.RecurringMinDays {
+			out = append(out, c)
+		}
+	}
+
+	if err := markResolved(db, model, out, cfg.RecurringResolveScore); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if len(out[i].Days) != len(out[j].Days) {
+			return len(out[i].Days) > len(out[j].Days)
+		}
+		return out[i].LastSeen() > out[j].LastSeen()
+	})
+	return out, nil
+}
+
+func loadLoggedQuestions(db *sql.DB, model, from string) ([]loggedQuestion, error) {
+	rows, err := db.Query(`
+		SELECT date, source, text, vec, dim FROM question_log
+		WHERE model=? AND date >= ?
+		ORDER BY date, id
+	`, model, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []loggedQuestion
+	for rows.Next() {
+		var (
+			q    loggedQuestion
+			blob []byte
+			dim  int
+		)
+		if err := rows.Scan(&q.Date, &q.Source, &q.Text, &blob, &dim); err != nil {
+			continue
+		}
+		if v, ok := decodeVec(blob, dim); ok {
+			q.vec = v
+			out = append(out, q)
+		}
+	}
+	return out, rows.Err()
+}
+
+// clusterQuestions：按时间顺序单遍聚类，问题与簇中心相似度 ≥ threshold 即归入
+func clusterQuestions(qs []loggedQuestion, threshold float64) []*QuestionCluster {
+	var clusters []*QuestionCluster
+	for _, q := range qs {
+		var (
+			best    *QuestionCluster
+			bestCos = threshold
+		)
+		for _, c := range clusters {
+			if cos := cosineF32(q.vec, c.centroid); cos >= bestCos {
+				best, bestCos = c, cos
+			}
+		}
+		if best == nil {
+			best = &QuestionCluster{}
+			clusters = append(clusters, best)
+		}
+		best.Questions = append(best.Questions, q)
+
+		vs := make([][]float32, len(best.Questions))
+		for i, x := range best.Questions {
+			vs[i] = x.vec
+		}
+		best.centroid = meanVector(vs)
+	}
+
+	for _, c := range clusters {
+		days := make(map[string]bool)
+		rep, repCos := "", -1.0
+		for _, q := range c.Questions {
+			days[q.Date] = true
+			if cos := cosineF32(q.vec, c.centroid); cos > repCos {
+				rep, repCos = q.Text, cos
+			}
+		}
+		c.Days = sortedStrings(days)
+		c.Rep = rep
+	}
+	return clusters
+}
+
+func sortedStrings(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// markResolved：最后一次出现之后的 summary 中，找与簇中心最相似的“进展类”条目
+func markResolved(db *sql.DB, model string, clusters []*QuestionCluster, threshold float64) error {
+	if len(clusters) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(resolvingFields)), ",")
+	args := []any{model}
+	for _, f := range resolvingFields {
+		args = append(args, f)
+	}
+
+	rows, err := db.Query(`
+		SELECT s.type, s.period_key, s.start_date, e.item, e.vec, e.dim
+		FROM embeddings e
+		JOIN summaries s ON s.id = e.summary_id
+		WHERE e.model = ? AND e.field IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			typ, key, start, item string
+			blob                  []byte
+			dim                   int
+		)
+		if err := rows.Scan(&typ, &key, &start, &item, &blob, &dim); err != nil {
+			continue
+		}
+		v, ok := decodeVec(blob, dim)
+		if !ok {
+			continue
+		}
+		for _, c := range clusters {
+			if start <= c.LastSeen() {
+				continue
+			}
+			cos := cosineF32(c.centroid, v)
+			if cos >= threshold && cos > c.ResolvedScore {
+				c.ResolvedType, c.ResolvedKey, c.ResolvedItem, c.ResolvedScore = typ, key, item, cos
+			}
+		}
+	}
+	return rows.Err()
+}
+
+/*
+========================
+Output
+========================
+*/
+
+func formatRecurring(clusters []*QuestionCluster, cfg Config, days int) string {
+	if len(clusters) == 0 {
+		return fmt.Sprintf("no recurring questions in the last %d days (need ≥%d distinct days)", days, cfg.RecurringMinDays)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🔁 %d recurring questions（最近 %d 天，至少出现在 %d 个不同日子）\n", len(clusters), days, cfg.RecurringMinDays))
+	for i, c := range clusters {
+		b.WriteString(fmt.Sprintf("\n%d. %s\n", i+1, c.Rep))
+		b.WriteString(fmt.Sprintf("   %d 次 · %d 天 · first %s · last %s\n", len(c.Questions), len(c.Days), c.FirstSeen(), c.LastSeen()))
+		for _, q := range c.Questions {
+			if q.Text == c.Rep {
+				continue
+			}
+			b.WriteString(fmt.Sprintf("   - %s [%s] %s\n", q.Date, q.Source, firstLine(q.Text)))
+		}
+		if c.ResolvedKey != "" {
+			b.WriteString(fmt.Sprintf("   ✅ 可能已解决：%s %s「%s」(%.2f)\n", c.ResolvedKey, c.ResolvedType, c.ResolvedItem, c.ResolvedScore))
+		} else {
+			b.WriteString("   ⏳ 之后的记录中还没有看到答案\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func parseRecurringArgs(input string, def int) (int, error) {
+	fields := strings.Fields(input)
+	switch {
+	case len(fields) == 0:
+		return def, nil
+	case len(fields) == 2 && fields[0] == "--days":
+		var n int
+		if _, err := fmt.Sscanf(fields[1], "%d", &n); err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid --days %q", fields[1])
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("usage: /recurring [--days N]")
+	}
+}
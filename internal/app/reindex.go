@@ -3,6 +3,7 @@ package app
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 /*
@@ -11,18 +12,37 @@ Reindex (Embedding Backfill)
 ========================
 */
 
-func Reindex(db *sql.DB, cfg Config, typ string) error {
+type ReindexOptions struct {
+	Type  string // daily|weekly|monthly|all
+	Model string // 为空 = active model
+	Stale bool   // 只刷新 text hash / embedder 已变化的向量（默认缺失和过期都处理）
+}
+
+// Reindex：补齐 model 的向量；model 为空 = active model
+// 指定非 active model 时，该 model 记为 pending（新 summary 会双写），用于并行迁移
+func Reindex(db *sql.DB, cfg Config, opts ReindexOptions) error {
+	active := activeEmbedModel(db, cfg)
+	model := opts.Model
+	if model == "" {
+		model = active
+	}
+	if model != active {
+		if err := setSetting(db, settingEmbedModelPending, model); err != nil {
+			return err
+		}
+	}
+
 	var rows *sql.Rows
 	var err error
 
-	switch typ {
+	switch opts.Type {
 	case "daily", "weekly", "monthly":
 		rows, err = db.Query(`
 			SELECT id, type, period_key, json
 			FROM summaries
 			WHERE type = ?
 			ORDER BY period_key
-		`, typ)
+		`, opts.Type)
 
 	case "all":
 		rows, err = db.Query(`
@@ -32,59 +52,122 @@ func Reindex(db *sql.DB, cfg Config, typ string) error {
 		`)
 
 	default:
-		return fmt.Errorf("unknown reindex type: %s", typ)
+		return fmt.Errorf("unknown reindex type: %s", opts.Type)
 	}
 
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-
-	var (
-		total   int
-		created int
-		skipped int
-	)
 
+	// 先读完再写：避免持有读游标的同时写 embeddings
+	type target struct {
+		id  int64
+		typ string
+		key string
+		js  string
+	}
+	var targets []target
 	for rows.Next() {
-		var (
-			id  int64
-			sty string
-			key string
-			js  string
-		)
-		if err := rows.Scan(&id, &sty, &key, &js); err != nil {
+		var t target
+		if err := rows.Scan(&t.id, &t.typ, &t.key, &t.js); err != nil {
 			continue
 		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+
+	var (
+		total     int
+		created   int
+		refreshed int
+		skipped   int
+	)
+
+	embedder := embedderIdentity(cfg, model)
+
+	for _, t := range targets {
 		total++
 
-		// 如果已有 embedding，跳过
-		if hasEmbedding(db, id, embedModel) {
+		// 从 JSON 动态提取 indexText
+		indexText := extractIndexText(t.js)
+		if indexText == "" {
 			skipped++
 			continue
 		}
 
-		// 从 JSON 动态提取 indexText
-		indexText := extractIndexText(js)
-		if indexText == "" {
+		hash := indexHash(indexText, extractIndexItems(t.js))
+		state := lookupEmbeddingState(db, t.id, model, hash, embedder)
+		switch {
+		case state == embeddingFresh:
+			skipped++
+			continue
+		case opts.Stale && state == embeddingMissing:
+			// --stale 只修复已存在但过期的向量
 			skipped++
 			continue
 		}
 
-		err := ensureEmbedding(db, cfg, indexText, sty, key)
+		err := ensureEmbeddingForModel(db, cfg, model, indexText, t.typ, t.key)
 		if err != nil {
-			fmt.Printf("[warn] failed to embed %s %s: %v\n", sty, key, err)
+			fmt.Printf("[warn] failed to embed %s %s: %v\n", t.typ, t.key, err)
 			continue
 		}
 
-		fmt.Printf("[ok] embedded %s %s\n", sty, key)
-		created++
+		if state == embeddingStale {
+			fmt.Printf("[ok] refreshed %s %s\n", t.typ, t.key)
+			refreshed++
+		} else {
+			fmt.Printf("[ok] embedded %s %s\n", t.typ, t.key)
+			created++
+		}
 	}
 
 	fmt.Printf(
-		"[reindex done] total=%d created=%d skipped=%d\n",
-		total, created, skipped,
+		"[reindex done] model=%s total=%d created=%d refreshed=%d skipped=%d\n",
+		model, total, created, refreshed, skipped,
 	)
 
+	if model != active {
+		if c, err := coverageFor(db, model); err == nil {
+			fmt.Printf("[coverage] %s %d/%d", model, c.Covered, c.Total)
+			if c.Complete() {
+				fmt.Printf(" — ready: /embeddings switch %s", model)
+			}
+			fmt.Println()
+		}
+	}
+
 	return nil
 }
+
+/*
+========================
+Stale Notice
+========================
+*/
+
+// staleVectorNotice：active model 中记录旧（没有 hash / embedder）或 embedder 已变化的 summary 数，
+// 只用 SQL 计数（启动时与 /search --explain 调用，不重新计算文本 hash）；没有时返回空字符串
+func staleVectorNotice(db *sql.DB, cfg Config) string {
+	model := activeEmbedModel(db, cfg)
+	var legacy, changed int
+	err := db.QueryRow(`
+		SELECT
+		  COUNT(DISTINCT CASE WHEN text_hash = '' OR embedder = '' THEN summary_id END),
+		  COUNT(DISTINCT CASE WHEN text_hash <> '' AND embedder <> '' AND embedder <> ? THEN summary_id END)
+		FROM embeddings WHERE model = ?
+	`, embedderIdentity(cfg, model), model).Scan(&legacy, &changed)
+	if err != nil || legacy+changed == 0 {
+		return ""
+	}
+
+	var parts []string
+	if legacy > 0 {
+		parts = append(parts, fmt.Sprintf("%d summaries from an older index format", legacy))
+	}
+	if changed > 0 {
+		parts = append(parts, fmt.Sprintf("%d summaries from a different embedder setup", changed))
+	}
+	return fmt.Sprintf("[notice] stale %s vectors (%s); run /reindex --stale to refresh them",
+		model, strings.Join(parts, ", "))
+}
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+========================
+Related Memories
+- 用 summary 自身存储的向量作为 query，找最相似的其它时期
+- 排除自身，以及与它时间范围重叠的父 / 子时期（daily ⊂ weekly ⊂ monthly）
+========================
+*/
+
+// RelatedLink：写入 daily JSON 的 "related" 条目
+type RelatedLink struct {
+	Type      string  `json:"type"`
+	Key       string  `json:"key"`
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Score     float64 `json:"score"`
+	Gist      string  `json:"gist"`
+}
+
+type relatedOptions struct {
+	TopN       int
+	EndsBefore string // 只保留 end_date < EndsBefore 的 summary（生成 daily 时只看过去）
+}
+
+// FindRelated：/related <type> <key> [--top N]
+func FindRelated(db *sql.DB, cfg Config, typ, key string, opts relatedOptions) ([]SearchHit, error) {
+	if err := validatePeriodKey(typ, key); err != nil {
+		return nil, err
+	}
+
+	var (
+		sid        int64
+		start, end string
+	)
+	err := db.QueryRow(`SELECT id, start_date, end_date FROM summaries WHERE type=? AND period_key=?`, typ, key).
+		Scan(&sid, &start, &end)
+	if err != nil {
+		return nil, fmt.Errorf("no %s summary for %s", typ, key)
+	}
+
+	model := activeEmbedModel(db, cfg)
+
+	// 1. query 向量 = 自身整段文本向量（多个 chunk 取平均）
+	docVecs, err := loadDocVectors(db, model, "WHERE e.model = ? AND e.summary_id = ?", model, sid)
+	if err != nil {
+		return nil, err
+	}
+	qv := meanVector(docVecs[sid])
+	if qv == nil {
+		return nil, fmt.Errorf("%s %s has no embedding for model %s; run /reindex", typ, key, model)
+	}
+
+	// 2. 其它 summary 的整段文本向量
+	others, err := loadDocVectors(db, model, "WHERE e.model = ? AND e.summary_id <> ?", model, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT id, type, period_key, start_date, end_date, json, text FROM summaries WHERE id <> ?`, sid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var h SearchHit
+		var js string
+		if err := rows.Scan(&h.ID, &h.Type, &h.Date, &h.StartDate, &h.EndDate, &js, &h.Doc); err != nil {
+			continue
+		}
+
+		// 父 / 子时期：类型不同且时间范围重叠
+		if h.Type != typ && h.StartDate <= end && h.EndDate >= start {
+			continue
+		}
+		if opts.EndsBefore != "" && h.EndDate >= opts.EndsBefore {
+			continue
+		}
+
+		best := math.Inf(-1)
+		for _, v := range others[h.ID] {
+			if c := cosineF32(qv, v); c > best {
+				best = c
+				h.vec = v
+			}
+		}
+		if h.vec == nil || best < cfg.RelatedMinScore {
+			continue
+		}
+
+		h.Score = best
+		h.Cosine = best
+		h.Text = extractHumanText(js)
+		hits = append(hits, h)
+	}
+
+	sortHitsByScore(hits)
+	return truncateHits(hits, opts.TopN), nil
+}
+
+// loadDocVectors：整段文本向量（field 为空），按 summary_id 分组
+func loadDocVectors(db *sql.DB, model, where string, args ...any) (map[int64][][]float32, error) {
+	rows, err := db.Query(`
+		SELECT e.summary_id, e.vec, e.dim
+		FROM embeddings e
+		`+where+` AND e.field = ''
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64][][]float32)
+	for rows.Next() {
+		var (
+			id   int64
+			blob []byte
+			dim  int
+		)
+		if err := rows.Scan(&id, &blob, &dim); err != nil {
+			continue
+		}
+		if v, ok := decodeVec(blob, dim); ok {
+			out[id] = append(out[id], v)
+		}
+	}
+	return out, rows.Err()
+}
+
+func meanVector(vs [][]float32) []float32 {
+	if len(vs) == 0 {
+		return nil
+	}
+	out := make([]float32, len(vs[0]))
+	for _, v := range vs {
+		if len(v) != len(out) {
+			continue
+		}
+		// 先归一化，避免长 chunk 主导方向
+		n := l2norm(v)
+		if n == 0 {
+			continue
+		}
+		for i := range v {
+			out[i] += float32(float64(v[i]) / n)
+		}
+	}
+	if l2norm(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+/*
+========================
+Daily Links
+========================
+*/
+
+// linkRelatedToDaily：新 daily 生成后，把最相似的过往时期写入 daily JSON 的 "related"
+func linkRelatedToDaily(cfg Config, db *sql.DB, date, outPath string) error {
+	hits, err := FindRelated(db, cfg, "daily", date, relatedOptions{
+		TopN:       cfg.RelatedTopK,
+		EndsBefore: date,
+	})
+	if err != nil || len(hits) == 0 {
+		return err
+	}
+
+	js, ok := loadSummaryJSON(db, "daily", date)
+	if !ok {
+		return nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(js), &obj); err != nil {
+		return err
+	}
+
+	links := make([]RelatedLink, 0, len(hits))
+	for _, h := range hits {
+		links = append(links, RelatedLink{
+			Type:      h.Type,
+			Key:       h.Date,
+			StartDate: h.StartDate,
+			EndDate:   h.EndDate,
+			Score:     math.Round(h.Score*1000) / 1000,
+			Gist:      firstLine(h.Text),
+		})
+	}
+	obj["related"] = links
+
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// related 不参与索引（不在 indexItemFields 中），只更新 json，不影响向量新鲜度
+	if _, err := db.Exec(`UPDATE summaries SET json=? WHERE type='daily' AND period_key=?`, string(b), date); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, b, 0644)
+}
+
+// loadRelatedLinks：读取 daily JSON 中的 related（供 chat context 使用）
+func loadRelatedLinks(cfg Config, date string) []RelatedLink {
+	b, err := os.ReadFile(filepath.Join(cfg.LogDir, date+".daily.json"))
+	if err != nil {
+		return nil
+	}
+	var obj struct {
+		Related []RelatedLink `json:"related"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil
+	}
+	return obj.Related
+}
+
+// relatedLinkLine：/show 中 related 的一行
+func relatedLinkLine(l RelatedLink) string {
+	line := fmt.Sprintf("%s %s（%.2f）", l.Key, l.Type, l.Score)
+	if l.Gist != "" {
+		line += " · " + l.Gist
+	}
+	return line
+}
+
+// stripRelated：related 只用于导航，不作为总结内容进入 weekly / chat 的输入
+func stripRelated(js string) string {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(js), &obj); err != nil {
+		return js
+	}
+	if _, ok := obj["related"]; !ok {
+		return js
+	}
+	delete(obj, "related")
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return js
+	}
+	return string(b)
+}
+
+// formatRelatedBlock：“这和你在 2025-03 做的事情很像”
+func formatRelatedBlock(links []RelatedLink) string {
+	if len(links) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("今天的内容与你过去的这些时期相似（可以自然地提醒用户，例如“这和你 2025-03 做的事情很像”）：\n")
+	for _, l := range links {
+		b.WriteString(fmt.Sprintf("- %s（%s，%s ~ %s，相似度 %.2f）", l.Key, l.Type, l.StartDate, l.EndDate, l.Score))
+		if l.Gist != "" {
+			b.WriteString("：")
+			b.WriteString(l.Gist)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// parseRelatedArgs：/related <type> <key> [--top N]
+func parseRelatedArgs(input string, defaultTop int) (typ, key string, top int, err error) {
+	top = defaultTop
+	var args []string
+	parts := strings.Fields(input)
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "--top" && i+1 < len(parts) {
+			if _, err := fmt.Sscanf(parts[i+1], "%d", &top); err != nil || top <= 0 {
+				return "", "", 0, fmt.Errorf("invalid --top: %s", parts[i+1])
+			}
+			i++
+			continue
+		}
+		args = append(args, parts[i])
+	}
+	if len(args) != 2 {
+		return "", "", 0, fmt.Errorf("usage: /related <type> <key> [--top N]")
+	}
+	return args[0], args[1], top, nil
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Rerank (second stage)
+- 第一阶段：SearchWithOptions 取 top N 候选
+- 第二阶段：llama.cpp /v1/rerank（cross-encoder）重排
+- reranker 不可用时：回退到第一阶段顺序
+========================
+*/
+
+// reranker 是可选组件：超时要短，不能拖慢 chat
+var rerankHTTPClient = &http.Client{
+	Timeout: 15 * time.Second,
+}
+
+type rerankResp struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// RetrieveMemories：Ask / BuildChatContext 使用的检索入口（search + rerank）
+// opts.TopK 为最终返回条数（0 = cfg.SearchTopK）
+func RetrieveMemories(db *sql.DB, cfg Config, query string, opts SearchOptions) ([]SearchHit, error) {
+	topK := cfg.SearchTopK
+	if opts.TopK > 0 {
+		topK = opts.TopK
+	}
+
+	if !rerankEnabled(cfg) {
+		return SearchWithOptions(db, cfg, query, opts)
+	}
+
+	first := opts
+	first.TopK = max(cfg.RerankTopN, topK)
+	hits, err := SearchWithOptions(db, cfg, query, first)
+	if err != nil || len(hits) <= 1 {
+		return truncateHits(hits, topK), err
+	}
+
+	reranked, err := rerankHits(cfg, query, hits)
+	if err != nil {
+		// ✅ reranker 不可达 / 出错：回退到 cosine 顺序
+		return truncateHits(hits, topK), nil
+	}
+
+	return truncateHits(reranked, topK), nil
+}
+
+func rerankEnabled(cfg Config) bool {
+	return cfg.RerankURL != "" && cfg.RerankModel != "" && cfg.RerankTopN > 0
+}
+
+// rerankHits：调用 reranker，对 hits 按 relevance_score 降序重排
+func rerankHits(cfg Config, query string, hits []SearchHit) ([]SearchHit, error) {
+	docs := make([]string, len(hits))
+	for i, h := range hits {
+		docs[i] = h.Doc
+		if strings.TrimSpace(docs[i]) == "" {
+			docs[i] = h.Text
+		}
+	}
+
+	payload := map[string]any{
+		"model":     cfg.RerankModel,
+		"query":     query,
+		"documents": docs,
+		"top_n":     len(docs),
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", cfg.RerankURL, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := rerankHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rerank http error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var rr rerankResp
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		return nil, fmt.Errorf("decode rerank response failed: %w", err)
+	}
+	if len(rr.Results) == 0 {
+		return nil, fmt.Errorf("empty rerank results")
+	}
+
+	out := make([]SearchHit, 0, len(rr.Results))
+	seen := make(map[int]bool, len(rr.Results))
+	for _, r := range rr.Results {
+		if r.Index < 0 || r.Index >= len(hits) || seen[r.Index] {
+			continue
+		}
+		seen[r.Index] = true
+
+		h := hits[r.Index]
+		h.Rerank = r.RelevanceScore
+		h.Reranked = true
+		out = append(out, h)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Rerank > out[j].Rerank
+	})
+
+	// reranker 漏掉的候选放在最后，保持原顺序
+	for i, h := range hits {
+		if !seen[i] {
+			out = append(out, h)
+		}
+	}
+
+	return out, nil
+}
+
+func truncateHits(hits []SearchHit, k int) []SearchHit {
+	if k > 0 && len(hits) > k {
+		return hits[:k]
+	}
+	return hits
+}
@@ -38,6 +38,14 @@ func Run() {
 
 	fmt.Println("🧠 Local AI Chat")
 	fmt.Println("Type exit to quit, /help for commands")
+	if sess, err := lw.Session(); err == nil {
+		fmt.Printf("Session %s (%d turns) — /new to start another\n", sess.Label(), sess.Turns)
+	} else {
+		fmt.Println("[warn] chat session unavailable:", err)
+	}
+	if notice := staleVectorNotice(db, cfg); notice != "" {
+		fmt.Println(notice)
+	}
 	fmt.Println()
 
 	// ==============================
@@ -108,16 +116,7 @@ func Run() {
 				fmt.Println("chat error:", err)
 			}
 		} else {
-			answer := streamChat(input)
-
-			_ = lw.WriteRecord(map[string]string{
-				"role":    "user",
-				"content": input,
-			})
-			_ = lw.WriteRecord(map[string]string{
-				"role":    "assistant",
-				"content": answer,
-			})
+			plainChatTurn(lw, input, recordKindChat)
 		}
 
 		fmt.Println("\n------------------\n")
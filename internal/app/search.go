@@ -1,15 +1,10 @@
 package app
 
 import (
-	"bytes"
 	"database/sql"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
-	"net/http"
-	"sort"
 	"strings"
 	"time"
 )
@@ -25,16 +20,34 @@ type SearchHit struct {
 	Type  string
 	Date  string
 	Text  string
-}
 
-/*
-========================
-HTTP Client (avoid hang)
-========================
-*/
+	// ranking 细节（/search --explain）
+	ID         int64
+	StartDate  string
+	EndDate    string
+	Cosine     float64 // 原始余弦相似度
+	Recency    float64 // 时间衰减系数
+	Boost      float64 // 类型加权系数
+	MMR        float64 // MMR 选中时的得分
+	Redundancy float64 // 与已选结果的最大相似度
+	Rerank     float64 // reranker relevance_score
+	Reranked   bool
+	Chunk      int     // 命中的向量序号
+	MatchField string  // 命中的字段（highlights / open_questions ...），空 = 整段文本
+	MatchItem  string  // 命中的条目原文
+	MatchScore float64 // 命中条目自身的余弦相似度
+	LineStart  int     // Type == "raw"：原始日志中的行号范围
+	LineEnd    int
+
+	Doc string // summaries.text（索引文本，供 reranker 使用）
+	vec []float32
+}
 
-var searchHTTPClient = &http.Client{
-	Timeout: 120 * time.Second,
+// SearchOptions：检索参数（零值 = 使用 cfg 默认）
+type SearchOptions struct {
+	TopK  int
+	Scope *TimeScope // 非空时只保留与区间重叠的 summary
+	AsOf  time.Time  // 非零时只保留在这一天之前结束的 summary，并以它作为“现在”
 }
 
 /*
@@ -44,13 +57,23 @@ Public Search API
 */
 
 func SearchWithScore(db *sql.DB, cfg Config, query string) ([]SearchHit, error) {
+	return SearchWithOptions(db, cfg, query, SearchOptions{})
+}
+
+func SearchWithOptions(db *sql.DB, cfg Config, query string, opts SearchOptions) ([]SearchHit, error) {
+	topK := cfg.SearchTopK
+	if opts.TopK > 0 {
+		topK = opts.TopK
+	}
+
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil, nil
 	}
 
-	// 1. embed query
-	qv, qn, err := embedText(query)
+	// 1. embed query（必须与索引使用同一个 model）
+	model := activeEmbedModel(db, cfg)
+	qv, qn, err := embedText(cfg, model, query)
 	if err != nil {
 		return nil, err
 	}
@@ -59,35 +82,180 @@ func SearchWithScore(db *sql.DB, cfg Config, query string) ([]SearchHit, error)
 		return nil, nil
 	}
 
-	// 2. load all embeddings
-	rows, err := db.Query(`
-		SELECT s.type, s.period_key, s.json, e.vec, e.l2, e.dim
-		FROM embeddings e
-		JOIN summaries s ON s.id = e.summary_id
-		WHERE e.model = ?
-	`, embedModel)
+	now := time.Now().In(cfg.Location)
+	asOfKey := ""
+	if !opts.AsOf.IsZero() {
+		now = opts.AsOf
+		asOfKey = opts.AsOf.Format("2006-01-02")
+	}
+	broad := isBroadQuery(query)
+
+	// 2. score all vectors, aggregate per summary
+	cands, err := matchSummaries(db, model, qv, qn)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
+	// 3. attach summary metadata + weighting
 	var hits []SearchHit
 
+	srows, err := loadSummaryRows(db, candidateIDs(cands, cfg.SearchItemSecondWeight, cfg.SearchMinScore))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range srows {
+		m := cands[r.ID]
+
+		if opts.Scope != nil && !opts.Scope.overlaps(r.StartDate, r.EndDate) {
+			continue
+		}
+		if asOfKey != "" && r.EndDate >= asOfKey {
+			continue
+		}
+
+		cos := m.score(cfg.SearchItemSecondWeight)
+
+		// 用户明确指定了时间范围时，不再按“新旧”衰减
+		rec := 1.0
+		if opts.Scope == nil {
+			rec = recencyFactor(cfg, r.EndDate, now)
+		}
+		boost := typeBoost(cfg, r.Type, broad) * scopeTypeBoost(opts.Scope, r.Type)
+
+		hits = append(hits, SearchHit{
+			Score:      cos * rec * boost,
+			Type:       r.Type,
+			Date:       r.Key,
+			Text:       extractHumanText(r.JSON),
+			ID:         r.ID,
+			StartDate:  r.StartDate,
+			EndDate:    r.EndDate,
+			Cosine:     cos,
+			Recency:    rec,
+			Boost:      boost,
+			Chunk:      m.bestChunk,
+			MatchField: m.bestField,
+			MatchItem:  m.bestItem,
+			MatchScore: m.best,
+			Doc:        r.Text,
+			vec:        m.vector(),
+		})
+	}
+
+	// 4. sort by weighted score desc
+	sortHitsByScore(hits)
+
+	// 5. candidate pool → MMR → topK
+	if pool := max(cfg.SearchCandidateK, topK); len(hits) > pool {
+		hits = hits[:pool]
+	}
+	hits = rerankMMR(hits, topK, cfg.SearchMMRLambda)
+
+	return hits, nil
+}
+
+/*
+========================
+Per-summary Aggregation
+========================
+*/
+
+// candidateIDs：得分不低于 minScore 的 summary id
+func candidateIDs(cands map[int64]*summaryMatch, secondWeight, minScore float64) []int64 {
+	ids := make([]int64, 0, len(cands))
+	for id, m := range cands {
+		if m.score(secondWeight) >= minScore {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+type summaryRow struct {
+	ID        int64
+	Type      string
+	Key       string
+	StartDate string
+	EndDate   string
+	JSON      string
+	Text      string
+}
+
+// 每批 IN 列表的参数个数（低于 SQLite 的绑定参数上限）
+const summaryIDBatch = 500
+
+// loadSummaryRows：只读取候选 summary，按 id 分批查询
+func loadSummaryRows(db *sql.DB, ids []int64) ([]summaryRow, error) {
+	var out []summaryRow
+	for len(ids) > 0 {
+		n := min(len(ids), summaryIDBatch)
+		args := make([]any, n)
+		for i, id := range ids[:n] {
+			args[i] = id
+		}
+		ids = ids[n:]
+
+		rows, err := db.Query(`
+			SELECT id, type, period_key, start_date, end_date, json, text
+			FROM summaries
+			WHERE id IN (?`+strings.Repeat(",?", n-1)+`)
+		`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var r summaryRow
+			if err := rows.Scan(&r.ID, &r.Type, &r.Key, &r.StartDate, &r.EndDate, &r.JSON, &r.Text); err != nil {
+				continue
+			}
+			out = append(out, r)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// matchSummaries：用 query 向量给 model 下的所有向量打分，按 summary 聚合
+func matchSummaries(db *sql.DB, model string, qv []float32, qn float64) (map[int64]*summaryMatch, error) {
+	rows, err := db.Query(`
+		SELECT summary_id, chunk, field, item, vec, l2, dim
+		FROM embeddings
+		WHERE model = ?
+	`, model)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mismatched int
+		indexDim   int
+	)
+
+	cands := make(map[int64]*summaryMatch)
+
 	for rows.Next() {
 		var (
-			typ  string
-			key  string
-			js   string
-			blob []byte
-			l2   float64
-			dim  int
+			id    int64
+			chunk int
+			field string
+			item  string
+			blob  []byte
+			l2    float64
+			dim   int
 		)
-		if err := rows.Scan(&typ, &key, &js, &blob, &l2, &dim); err != nil {
+		if err := rows.Scan(&id, &chunk, &field, &item, &blob, &l2, &dim); err != nil {
 			continue
 		}
 
 		// ✅ 防线：维度必须匹配，否则 dotProduct 会产生错误分数
 		if dim != len(qv) {
+			mismatched++
+			indexDim = dim
 			continue
 		}
 		// ✅ 防线：避免除 0
@@ -95,87 +263,127 @@ func SearchWithScore(db *sql.DB, cfg Config, query string) ([]SearchHit, error)
 			continue
 		}
 
-		dot, ok := dotProductExactDim(qv, blob, dim)
+		vec, ok := decodeVec(blob, dim)
 		if !ok {
 			// blob 不完整/损坏，跳过
 			continue
 		}
 
-		score := dot / (qn * l2)
-		if math.IsNaN(score) || math.IsInf(score, 0) {
+		cos := dotF32(qv, vec) / (qn * l2)
+		if math.IsNaN(cos) || math.IsInf(cos, 0) {
 			continue
 		}
 
-		if score < cfg.SearchMinScore {
-			continue
+		m := cands[id]
+		if m == nil {
+			m = &summaryMatch{}
+			cands[id] = m
 		}
+		m.add(cos, chunk, field, item, vec)
+	}
+	rows.Close()
 
-		hits = append(hits, SearchHit{
-			Score: score,
-			Type:  typ,
-			Date:  key,
-			Text:  extractHumanText(js),
-		})
+	// ✅ 不再静默返回空：索引与 query 维度不一致说明 model 被换过
+	if len(cands) == 0 && mismatched > 0 {
+		return nil, fmt.Errorf(
+			"embedding dim mismatch: %d vectors of model %s have dim %d, query dim %d; run /reindex --model %s",
+			mismatched, model, indexDim, len(qv), model,
+		)
 	}
 
-	// 3. sort by score desc
-	sort.Slice(hits, func(i, j int) bool {
-		return hits[i].Score > hits[j].Score
-	})
+	return cands, nil
+}
 
-	// 4. topK
-	if len(hits) > cfg.SearchTopK {
-		hits = hits[:cfg.SearchTopK]
+// summaryMatch：一个 summary 下所有向量（整段 chunk + 条目）的命中情况
+type summaryMatch struct {
+	best      float64
+	second    float64
+	bestChunk int
+	bestField string
+	bestItem  string
+	bestVec   []float32
+
+	docCos float64
+	docVec []float32 // 整段文本向量（MMR 用它衡量 summary 之间的冗余）
+}
+
+func (m *summaryMatch) add(cos float64, chunk int, field, item string, vec []float32) {
+	if field == "" && (m.docVec == nil || cos > m.docCos) {
+		m.docCos = cos
+		m.docVec = vec
 	}
 
-	return hits, nil
+	if m.bestVec == nil || cos > m.best {
+		m.second = m.best
+		m.best = cos
+		m.bestChunk = chunk
+		m.bestField = field
+		m.bestItem = item
+		m.bestVec = vec
+		return
+	}
+	if cos > m.second {
+		m.second = cos
+	}
+}
+
+// score：最佳向量为主，第二佳向量按权重加分（多个条目同时相关的 summary 排更前）
+// 只加不摊薄：不能低于 best，否则多条目的 summary 反而吃亏；上限 1，与余弦阈值同一尺度
+// /search 与 /evolution（topicTimeline）共用
+func (m *summaryMatch) score(secondWeight float64) float64 {
+	if secondWeight <= 0 || m.second <= 0 {
+		return m.best
+	}
+	return math.Min(m.best+secondWeight*m.second, 1)
+}
+
+func (m *summaryMatch) vector() []float32 {
+	if m.docVec != nil {
+		return m.docVec
+	}
+	return m.bestVec
 }
 
 /*
 ========================
-Embedding Helper
+Argument Parser
 ========================
 */
 
-func embedText(text string) ([]float32, float64, error) {
-	payload := map[string]any{
-		"model": embedModel,
-		"input": text,
-	}
-	b, err := json.Marshal(payload)
-	if err != nil {
-		return nil, 0, err
-	}
+func parseSearchArgs(input string) (query string, explain bool, asOf string) {
+	input, asOf = splitAsOf(input)
 
-	req, err := http.NewRequest("POST", embedURL, bytes.NewReader(b))
-	if err != nil {
-		return nil, 0, err
+	var q []string
+	for _, p := range strings.Fields(input) {
+		if p == "--explain" {
+			explain = true
+		} else {
+			q = append(q, p)
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return strings.Join(q, " "), explain, asOf
+}
 
-	resp, err := searchHTTPClient.Do(req)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer resp.Body.Close()
+// scopeQuery：识别 query 中的时间表达；返回去掉时间表达的 query 与检索参数
+// asOf 非零时，“上个月”等相对表达以 asOf 为基准
+func scopeQuery(cfg Config, query string, asOf time.Time) (string, SearchOptions) {
+	scope, rest := parseTimeScope(query, clockAt(cfg, asOf))
+	return rest, SearchOptions{Scope: scope, AsOf: asOf}
+}
 
-	// ✅ 必须检查状态码
-	if resp.StatusCode/100 != 2 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, 0, fmt.Errorf("embed http error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
+/*
+========================
+Embedding Helper
+========================
+*/
 
-	var er embedResp
-	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+// embedText：query 侧嵌入（加 profile 的 query 前缀）
+func embedText(cfg Config, model, text string) ([]float32, float64, error) {
+	vec, err := requestEmbedding(model, embedProfile(cfg, model).QueryPrefix+text)
+	if err != nil {
 		return nil, 0, err
 	}
-	if len(er.Data) == 0 || len(er.Data[0].Embedding) == 0 {
-		return nil, 0, fmt.Errorf("empty embedding")
-	}
-
-	vec := er.Data[0].Embedding
-	n := l2norm(vec)
-	return vec, n, nil
+	return vec, l2norm(vec), nil
 }
 
 /*
@@ -226,26 +434,6 @@ Vector Math
 ========================
 */
 
-// dotProductExactDim: 读取 blob 中 exactly dim 个 float32，与 q 做点积；
-// 任何读失败都返回 ok=false，避免“吞错导致分数乱飞”
-func dotProductExactDim(q []float32, blob []byte, dim int) (sum float64, ok bool) {
-	// 每个 float32 4 bytes
-	if len(blob) < dim*4 {
-		return 0, false
-	}
-
-	buf := bytes.NewReader(blob)
-
-	for i := 0; i < dim; i++ {
-		var x float32
-		if err := binary.Read(buf, binary.LittleEndian, &x); err != nil {
-			return 0, false
-		}
-		sum += float64(q[i] * x)
-	}
-	return sum, true
-}
-
 func l2norm(v []float32) float64 {
 	var s float64
 	for _, x := range v {
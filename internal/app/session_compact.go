@@ -0,0 +1,219 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+========================
+Session Compaction (rolling)
+- 当前会话中当天尚未压缩的轮次超过 SessionCompactTokens 时，
+  除最近 SessionKeepTurns 轮外，其余轮次与旧摘要一起压缩成新的“会话至今”摘要
+- 摘要只覆盖当天：跨天后第一次压缩不沿用前一天的摘要，保证 compaction 记录不把前一天的内容带进当天的 daily
+- 摘要存入 sessions.summary / summary_turn，并以 kind = compaction 写入 raw log（不可变记录）
+- Chat：摘要作为 session_summary 块注入，历史消息只发送摘要之后的轮次
+- Daily：当天原始对话超出单次上限时，用压缩记录代替它已覆盖的轮次
+========================
+*/
+
+// sessionTurn：会话中的一轮（user 行 + 其后的 assistant 行）
+type sessionTurn struct {
+	Turn  int
+	Lines []rawLogLine
+}
+
+func (t sessionTurn) text() string {
+	var b strings.Builder
+	for _, l := range t.Lines {
+		who := "用户"
+		if l.Role == "assistant" {
+			who = "助手"
+		}
+		b.WriteString(who + "：" + strings.TrimSpace(l.Content) + "\n")
+	}
+	return b.String()
+}
+
+// loadSessionTurns：dates 这几天中属于 session、轮次 > after 的对话，按轮次分组
+func loadSessionTurns(cfg Config, dates []string, session string, after int) []sessionTurn {
+	var turns []sessionTurn
+	for _, date := range dates {
+		lines, _, err := loadRawTranscript(cfg, date)
+		if err != nil {
+			continue
+		}
+		for _, l := range lines {
+			if l.Session != session || l.Turn <= after {
+				continue
+			}
+			if (l.Role != "user" && l.Role != "assistant") || l.Kind == recordKindAsk {
+				continue
+			}
+			if n := len(turns); n == 0 || turns[n-1].Turn != l.Turn {
+				turns = append(turns, sessionTurn{Turn: l.Turn})
+			}
+			cur := &turns[len(turns)-1]
+			cur.Lines = append(cur.Lines, l)
+		}
+	}
+	return turns
+}
+
+// compactSession：每次对话前调用；未达到阈值时什么都不做
+func compactSession(lw *LogWriter, cfg Config, db *sql.DB, date string) error {
+	if cfg.ChatHistoryMode == chatHistorySystem || cfg.SessionCompactTokens <= 0 {
+		return nil
+	}
+
+	s, err := lw.Session()
+	if err != nil {
+		return err
+	}
+	sid := strconv.FormatInt(s.ID, 10)
+	turns := loadSessionTurns(cfg, []string{date}, sid, s.SummaryTurn)
+
+	tokens := 0
+	for _, t := range turns {
+		tokens += estimateTokens(t.text())
+	}
+	if tokens <= cfg.SessionCompactTokens {
+		return nil
+	}
+
+	keep := max(cfg.SessionKeepTurns, 1)
+	if len(turns) <= keep {
+		return nil
+	}
+	fold := turns[:len(turns)-keep]
+
+	previous := s.Summary
+	if !compactedOn(cfg, date, sid) {
+		previous = ""
+	}
+
+	out, err := callLLMNonStream(buildSessionCompactPrompt(previous, fold))
+	if err != nil {
+		return err
+	}
+	summary := strings.TrimSpace(out)
+	if summary == "" {
+		return fmt.Errorf("empty session summary")
+	}
+
+	upto := fold[len(fold)-1].Turn
+	if _, err := db.Exec(`UPDATE sessions SET summary=?, summary_turn=? WHERE id=?`, summary, upto, s.ID); err != nil {
+		return err
+	}
+	return lw.WriteRecord(LogRecord{
+		Role:       "system",
+		Kind:       recordKindCompaction,
+		Content:    summary,
+		CoversTurn: upto,
+	})
+}
+
+// compactedOn：date 当天的原始日志中是否已有该会话的压缩记录（没有 = 跨天后的第一次压缩）
+func compactedOn(cfg Config, date, session string) bool {
+	lines, _, err := loadRawTranscript(cfg, date)
+	if err != nil {
+		return false
+	}
+	for _, l := range lines {
+		if l.Kind == recordKindCompaction && l.Session == session {
+			return true
+		}
+	}
+	return false
+}
+
+func buildSessionCompactPrompt(previous string, turns []sessionTurn) string {
+	if previous == "" {
+		previous = "（无，这是第一次压缩）"
+	}
+
+	var transcript strings.Builder
+	for _, t := range turns {
+		transcript.WriteString(fmt.Sprintf("【第 %d 轮】\n", t.Turn))
+		transcript.WriteString(t.text())
+	}
+
+	return fmt.Sprintf(`
+你在为一段很长的对话做滚动摘要，供后续对话继续使用。
+
+【之前的摘要】
+%s
+
+【需要并入摘要的新对话】
+%s
+【要求】
+- 输出一份更新后的完整摘要（不是只写新增部分），300 字以内
+- 保留：正在讨论的问题、已经得出的结论与决定、用户给出的约束和偏好、尚未解决的问题
+- 去掉寒暄和重复内容；不要补充对话中没有的信息
+- 只输出摘要正文，不要标题和解释
+`, previous, transcript.String())
+}
+
+// sessionSummaryBlock：当前会话的“会话至今”摘要（--as-of 模拟时不注入）
+func sessionSummaryBlock(db *sql.DB) string {
+	s, err := currentSession(db)
+	if err != nil || s.Summary == "" {
+		return ""
+	}
+	return fmt.Sprintf("这是本次会话前 %d 轮的摘要（之后的对话见消息历史）：\n%s", s.SummaryTurn, s.Summary)
+}
+
+/*
+========================
+Daily Reuse
+========================
+*/
+
+// foldCompactedTurns：每个会话只保留当天最新的压缩记录，并去掉它已覆盖的轮次
+func foldCompactedTurns(raw []byte) []byte {
+	lines := bytes.Split(raw, []byte("\n"))
+	recs := make([]LogRecord, len(lines))
+	ok := make([]bool, len(lines))
+
+	latest := make(map[string]int) // session → 最新压缩记录的行号
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if json.Unmarshal(line, &recs[i]) != nil {
+			continue
+		}
+		ok[i] = true
+		if recs[i].Kind == recordKindCompaction && recs[i].Session != "" {
+			latest[recs[i].Session] = i
+		}
+	}
+	if len(latest) == 0 {
+		return raw
+	}
+
+	var b bytes.Buffer
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if ok[i] {
+			r := recs[i]
+			if j, has := latest[r.Session]; has {
+				if r.Kind == recordKindCompaction && i != j {
+					continue
+				}
+				if r.Turn > 0 && r.Kind != recordKindCompaction && r.Turn <= recs[j].CoversTurn {
+					continue
+				}
+			}
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Chat Sessions
+- 每条 raw 记录由 LogWriter.WriteRecord 打上 session / turn（user 行开启新的一轮，之后的行沿用）
+- 当前会话保存在 settings.chat_session：重启后继续，跨午夜也不中断
+- /new /sessions /resume /rename；daily 生成时原始对话按会话分组
+========================
+*/
+
+const settingChatSession = "chat_session"
+
+type ChatSession struct {
+	ID           int64
+	Title        string
+	Turns        int
+	CreatedAt    string
+	LastActiveAt string
+	Summary      string // 滚动压缩的“会话至今”摘要（见 session_compact.go）
+	SummaryTurn  int    // 摘要覆盖到的轮次
+}
+
+func (s ChatSession) Label() string {
+	if s.Title == "" {
+		return fmt.Sprintf("#%d", s.ID)
+	}
+	return fmt.Sprintf("#%d「%s」", s.ID, s.Title)
+}
+
+func createSession(db *sql.DB, title string) (ChatSession, error) {
+	now := time.Now().Format(time.RFC3339)
+	res, err := db.Exec(`INSERT INTO sessions(title, created_at, last_active_at) VALUES(?, ?, ?)`,
+		strings.TrimSpace(title), now, now)
+	if err != nil {
+		return ChatSession{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return ChatSession{}, err
+	}
+	if err := setSetting(db, settingChatSession, strconv.FormatInt(id, 10)); err != nil {
+		return ChatSession{}, err
+	}
+	return loadSession(db, id)
+}
+
+func loadSession(db *sql.DB, id int64) (ChatSession, error) {
+	var s ChatSession
+	err := db.QueryRow(`SELECT id, title, turns, created_at, last_active_at, summary, summary_turn FROM sessions WHERE id=?`, id).
+		Scan(&s.ID, &s.Title, &s.Turns, &s.CreatedAt, &s.LastActiveAt, &s.Summary, &s.SummaryTurn)
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("no session #%d", id)
+	}
+	return s, err
+}
+
+// currentSession：settings 中记录的会话；不存在时新建一个
+func currentSession(db *sql.DB) (ChatSession, error) {
+	if v, ok := getSetting(db, settingChatSession); ok {
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+			if s, err := loadSession(db, id); err == nil {
+				return s, nil
+			}
+		}
+	}
+	return createSession(db, "")
+}
+
+func resumeSession(db *sql.DB, id int64) (ChatSession, error) {
+	s, err := loadSession(db, id)
+	if err != nil {
+		return s, err
+	}
+	return s, setSetting(db, settingChatSession, strconv.FormatInt(id, 10))
+}
+
+func renameSession(db *sql.DB, id int64, title string) error {
+	res, err := db.Exec(`UPDATE sessions SET title=? WHERE id=?`, strings.TrimSpace(title), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no session #%d", id)
+	}
+	return nil
+}
+
+// stampTurn：user 行开启新的一轮，其它行沿用当前轮次
+func stampTurn(db *sql.DB, id int64, role string) (int, error) {
+	now := time.Now().Format(time.RFC3339)
+	q := `UPDATE sessions SET last_active_at=? WHERE id=?`
+	if role == "user" {
+		q = `UPDATE sessions SET turns=turns+1, last_active_at=? WHERE id=?`
+	}
+	if _, err := db.Exec(q, now, id); err != nil {
+		return 0, err
+	}
+
+	var turn int
+	err := db.QueryRow(`SELECT turns FROM sessions WHERE id=?`, id).Scan(&turn)
+	return turn, err
+}
+
+func listSessions(db *sql.DB, limit int) ([]ChatSession, error) {
+	rows, err := db.Query(`
+		SELECT id, title, turns, created_at, last_active_at, summary, summary_turn
+		FROM sessions
+		ORDER BY last_active_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ChatSession
+	for rows.Next() {
+		var s ChatSession
+		if err := rows.Scan(&s.ID, &s.Title, &s.Turns, &s.CreatedAt, &s.LastActiveAt, &s.Summary, &s.SummaryTurn); err != nil {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func formatSessions(list []ChatSession, current int64, loc *time.Location) string {
+	if len(list) == 0 {
+		return "no sessions"
+	}
+
+	stamp := func(s string) string {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return s
+		}
+		return t.In(loc).Format("2006-01-02 15:04")
+	}
+
+	var b strings.Builder
+	for _, s := range list {
+		mark := " "
+		if s.ID == current {
+			mark = "*"
+		}
+		title := s.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		b.WriteString(fmt.Sprintf("%s #%-4d %-24s %4d turns  %s → %s\n",
+			mark, s.ID, title, s.Turns, stamp(s.CreatedAt), stamp(s.LastActiveAt)))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func parseSessionID(s string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(s), "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid session id %q", s)
+	}
+	return id, nil
+}
+
+// parseRenameArgs：/rename <title> 重命名当前会话；/rename #id <title> 重命名指定会话
+func parseRenameArgs(input string, current int64) (int64, string, error) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return 0, "", fmt.Errorf("usage: /rename [#id] <title>")
+	}
+	if strings.HasPrefix(fields[0], "#") {
+		id, err := parseSessionID(fields[0])
+		if err != nil {
+			return 0, "", err
+		}
+		if len(fields) == 1 {
+			return 0, "", fmt.Errorf("usage: /rename [#id] <title>")
+		}
+		return id, strings.Join(fields[1:], " "), nil
+	}
+	return current, strings.Join(fields, " "), nil
+}
+
+/*
+========================
+Session Transcript
+========================
+*/
+
+// sessionHistoryDates：会话跨午夜时，历史需要读取会话开始以来的几天（最多 days 天）
+func sessionHistoryDates(s ChatSession, date string, loc *time.Location, days int) []string {
+	end, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return []string{date}
+	}
+	start := end
+	if t, err := time.Parse(time.RFC3339, s.CreatedAt); err == nil {
+		start = time.Date(t.In(loc).Year(), t.In(loc).Month(), t.In(loc).Day(), 0, 0, 0, 0, loc)
+	}
+	if earliest := end.AddDate(0, 0, -(days - 1)); start.Before(earliest) {
+		start = earliest
+	}
+
+	var out []string
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		out = append(out, d.Format("2006-01-02"))
+	}
+	return out
+}
+
+// groupTranscriptBySession：daily 生成时把当天的原始记录按会话排在一起（组内保持原顺序）
+// 不插入标题行：会话由每行自身的 session 字段标明，切 chunk 后也不会丢失
+func groupTranscriptBySession(raw []byte) []byte {
+	var (
+		order  []string
+		groups = make(map[string][][]byte)
+	)
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var r RawLine
+		_ = json.Unmarshal(line, &r)
+		if _, ok := groups[r.Session]; !ok {
+			order = append(order, r.Session)
+		}
+		groups[r.Session] = append(groups[r.Session], line)
+	}
+	if len(order) <= 1 {
+		return raw
+	}
+
+	var b bytes.Buffer
+	for _, key := range order {
+		for _, line := range groups[key] {
+			b.Write(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+// sessionLabels：raw 中出现的会话 id → "#id「title」"（供 compactTranscript 写入每一行）
+func sessionLabels(db *sql.DB, raw []byte) map[string]string {
+	labels := make(map[string]string)
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		var r RawLine
+		if json.Unmarshal(line, &r) != nil || r.Session == "" {
+			continue
+		}
+		if _, ok := labels[r.Session]; ok {
+			continue
+		}
+		labels[r.Session] = "#" + r.Session
+		if id, err := strconv.ParseInt(r.Session, 10, 64); err == nil {
+			if s, err := loadSession(db, id); err == nil {
+				labels[r.Session] = s.Label()
+			}
+		}
+	}
+	return labels
+}
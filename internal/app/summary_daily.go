@@ -56,6 +56,16 @@ func ensureDaily(cfg Config, db *sql.DB, date string, force bool) error {
 		return err
 	}
 
+	// ---------- REUSE SESSION COMPACTIONS ----------
+	// 超出单次上限时，用会话压缩摘要代替它已覆盖的轮次
+	if int64(len(rawAll)) > cfg.MaxDailyJSONLBytes {
+		rawAll = foldCompactedTurns(rawAll)
+	}
+
+	// ---------- GROUP BY SESSION + STRIP METADATA ----------
+	// v1 记录带 id / 模型 / 用量等元数据，总结模型只需要 role / content / 会话（v0 记录同样适用）
+	rawAll = compactTranscript(groupTranscriptBySession(rawAll), cfg.Location, sessionLabels(db, rawAll))
+
 	// ---------- SPLIT INTO TOKEN-SAFE CHUNKS ----------
 	chunks := splitJSONLIntoChunks(rawAll, cfg.MaxDailyJSONLBytes)
 
@@ -147,6 +157,21 @@ func ensureDaily(cfg Config, db *sql.DB, date string, force bool) error {
 
 	// ---------- EMBEDDING ----------
 	_ = ensureEmbedding(db, cfg, indexText, "daily", date)
+
+	// ---------- RELATED LINKS ----------
+	if err := linkRelatedToDaily(cfg, db, date, outPath); err != nil {
+		fmt.Println("[warn] link related summaries failed:", err)
+	}
+
+	// ---------- QUESTION LOG ----------
+	if err := logDailyQuestions(cfg, db, date); err != nil {
+		fmt.Println("[warn] log daily questions failed:", err)
+	}
+
+	// ---------- OPEN QUESTIONS ----------
+	if err := trackOpenQuestions(cfg, db, date); err != nil {
+		fmt.Println("[warn] track open questions failed:", err)
+	}
 	return nil
 }
 
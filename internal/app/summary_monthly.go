@@ -188,6 +188,12 @@ func ensureMonthly(cfg Config, db *sql.DB, monthKey string, force bool) error {
 	// ---------- EMBEDDING ----------
 	_ = ensureEmbedding(db, cfg, indexText, "monthly", monthKey)
 
+	// ---------- COMMITMENTS ----------
+	// 先评估上月的 next_month_bets，再记录本月的
+	if err := trackCommitments(cfg, db, "monthly", monthKey, monthlyJSON); err != nil {
+		fmt.Println("[warn] track monthly commitments failed:", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,304 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+========================
+Summary Rendering (human readable)
+- daily / weekly / monthly 各自的字段顺序与中文标题
+- 未知字段按原名追加，保证“每个字段都能看到”
+- 检索词高亮（ANSI，设置 NO_COLOR 或输出不是终端时关闭）
+========================
+*/
+
+type summaryField struct {
+	Key   string
+	Label string
+}
+
+var summaryFieldsByType = map[string][]summaryField{
+	"daily": {
+		{"topics", "主题"},
+		{"patterns", "模式"},
+		{"highlights", "亮点"},
+		{"lowlights", "低谷"},
+		{"open_questions", "未解决的问题"},
+		{"user_facts_explicit", "明确的用户事实"},
+		{"related", "相似的过往时期"},
+	},
+	"weekly": {
+		{"themes", "本周主题"},
+		{"progress", "进展"},
+		{"recurring_blockers", "反复出现的阻碍"},
+		{"notable_decisions", "重要决定"},
+		{"next_week_focus", "下周重点"},
+	},
+	"monthly": {
+		{"trajectory", "轨迹"},
+		{"top_themes", "核心主题"},
+		{"wins", "收获"},
+		{"losses", "损失"},
+		{"systems_improvements", "系统改进"},
+		{"next_month_bets", "下月押注"},
+	},
+}
+
+// 头部字段：已在标题行体现，不再作为 section 重复输出
+var summaryHeaderKeys = map[string]bool{
+	"type": true, "date": true, "week_key": true, "week_start": true, "week_end": true,
+	"month": true, "month_start": true, "month_end": true,
+}
+
+// renderSummary：把 summary JSON 渲染为分段文本；terms 非空时高亮命中词
+func renderSummary(typ, key, js string, terms []string) string {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(js), &m); err != nil {
+		return js
+	}
+
+	var b strings.Builder
+	b.WriteString(summaryTitle(typ, key, m))
+	b.WriteString("\n")
+
+	done := make(map[string]bool)
+	for _, f := range summaryFieldsByType[typ] {
+		done[f.Key] = true
+		if f.Key == "related" {
+			writeSummarySection(&b, f.Label, relatedValueLines(m[f.Key]), terms)
+			continue
+		}
+		writeSummarySection(&b, f.Label, m[f.Key], terms)
+	}
+
+	// 其它字段（兼容旧数据 / 后续扩展）
+	var rest []string
+	for k := range m {
+		if !done[k] && !summaryHeaderKeys[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		writeSummarySection(&b, k, m[k], terms)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// relatedValueLines：related 链接按“key type（score）· gist”逐行展示
+func relatedValueLines(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var links []RelatedLink
+	if err := json.Unmarshal(b, &links); err != nil {
+		return v
+	}
+	lines := make([]any, 0, len(links))
+	for _, l := range links {
+		lines = append(lines, relatedLinkLine(l))
+	}
+	return lines
+}
+
+func summaryTitle(typ, key string, m map[string]any) string {
+	switch typ {
+	case "weekly":
+		return fmt.Sprintf("📅 weekly %s（%v ~ %v）", key, m["week_start"], m["week_end"])
+	case "monthly":
+		return fmt.Sprintf("📅 monthly %s（%v ~ %v）", key, m["month_start"], m["month_end"])
+	default:
+		return fmt.Sprintf("📅 %s %s", typ, key)
+	}
+}
+
+func writeSummarySection(b *strings.Builder, label string, v any, terms []string) {
+	lines := summaryValueLines(v)
+	if len(lines) == 0 {
+		return
+	}
+
+	b.WriteString("\n【")
+	b.WriteString(label)
+	b.WriteString("】\n")
+	for _, l := range lines {
+		b.WriteString("  - ")
+		b.WriteString(highlightTerms(l, terms))
+		b.WriteString("\n")
+	}
+}
+
+func summaryValueLines(v any) []string {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if s := strings.TrimSpace(x); s != "" {
+			return []string{s}
+		}
+		return nil
+	case []any:
+		var out []string
+		for _, it := range x {
+			if s := strings.TrimSpace(itemText(it)); s != "" {
+				out = append(out, s)
+			} else if it != nil {
+				out = append(out, compactJSON(it))
+			}
+		}
+		return out
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var out []string
+		for _, k := range keys {
+			out = append(out, fmt.Sprintf("%s: %s", k, compactJSON(x[k])))
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(x)}
+	}
+}
+
+func compactJSON(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+/*
+========================
+Term Highlighting
+========================
+*/
+
+const (
+	ansiHighlight = "\033[1;33m"
+	ansiReset     = "\033[0m"
+)
+
+// queryTerms：从检索词中提取用于高亮的词
+// 英文按空白 / 标点切分；较长的中文片段额外拆成双字词
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(t string) {
+		t = strings.TrimSpace(t)
+		if runeLen(t) < 2 || seen[strings.ToLower(t)] {
+			return
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+
+	for _, tok := range strings.FieldsFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) {
+		if strings.HasPrefix(tok, "--") {
+			continue
+		}
+		add(tok)
+
+		r := []rune(tok)
+		if len(r) > 4 && isCJKRune(r[0]) {
+			for i := 0; i+2 <= len(r); i++ {
+				add(string(r[i : i+2]))
+			}
+		}
+	}
+
+	// 长词优先，避免短词先匹配把长词拆开
+	sort.SliceStable(out, func(i, j int) bool {
+		return runeLen(out[i]) > runeLen(out[j])
+	})
+	return out
+}
+
+// colorEnabled：NO_COLOR 未设置且 stdout 是终端（重定向到文件 / 管道时不输出 ANSI）
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+func highlightTerms(s string, terms []string) string {
+	if len(terms) == 0 || !colorEnabled() {
+		return s
+	}
+
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		quoted = append(quoted, regexp.QuoteMeta(t))
+	}
+	re, err := regexp.Compile("(?i)(" + strings.Join(quoted, "|") + ")")
+	if err != nil {
+		return s
+	}
+	return re.ReplaceAllString(s, ansiHighlight+"$1"+ansiReset)
+}
+
+/*
+========================
+/show
+========================
+*/
+
+// ShowSummary：/show daily 2025-12-01 | weekly 2025-W49 | monthly 2025-12 [--raw]
+func ShowSummary(db *sql.DB, typ, key string, raw bool) error {
+	if err := validatePeriodKey(typ, key); err != nil {
+		return err
+	}
+
+	js, ok := loadSummaryJSON(db, typ, key)
+	if !ok {
+		return fmt.Errorf("no %s summary for %s", typ, key)
+	}
+
+	if raw {
+		fmt.Println(js)
+		return nil
+	}
+	fmt.Println(renderSummary(typ, key, js, nil))
+	return nil
+}
+
+var periodKeyPatterns = map[string]*regexp.Regexp{
+	"daily":   regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`),
+	"weekly":  regexp.MustCompile(`^\d{4}-W\d{2}$`),
+	"monthly": regexp.MustCompile(`^\d{4}-\d{2}$`),
+}
+
+func validatePeriodKey(typ, key string) error {
+	re, ok := periodKeyPatterns[typ]
+	if !ok {
+		return fmt.Errorf("unknown summary type: %s (daily|weekly|monthly)", typ)
+	}
+	if !re.MatchString(key) {
+		return fmt.Errorf("invalid %s key: %s", typ, key)
+	}
+	return nil
+}
@@ -195,6 +195,12 @@ func ensureWeekly(cfg Config, db *sql.DB, weekKey string, force bool) error {
 	// ---------- EMBEDDING ----------
 	_ = ensureEmbedding(db, cfg, indexText, "weekly", weekKey)
 
+	// ---------- COMMITMENTS ----------
+	// 先评估上周的 next_week_focus，再记录本周的
+	if err := trackCommitments(cfg, db, "weekly", weekKey, weeklyJSON); err != nil {
+		fmt.Println("[warn] track weekly commitments failed:", err)
+	}
+
 	return nil
 }
 
@@ -229,7 +235,7 @@ func collectDailySummariesForWeek(cfg Config, weekKey string) []string {
 		dateKey := d.Format("2006-01-02")
 		path := filepath.Join(cfg.LogDir, dateKey+".daily.json")
 		if b, err := os.ReadFile(path); err == nil {
-			out = append(out, strings.TrimSpace(string(b)))
+			out = append(out, strings.TrimSpace(stripRelated(string(b))))
 		}
 	}
 	return out
@@ -0,0 +1,381 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Time Scope (natural-language)
+- 从问题中识别时间表达（中 / 英），解析为 [Start, End] 日期区间
+- 检索只保留与区间重叠的 summary，并偏好粒度匹配的类型
+- 解析基准：cfg.Location 下的 now
+========================
+*/
+
+type TimeScope struct {
+	Start       time.Time // 含
+	End         time.Time // 含
+	Granularity string    // day | week | month | quarter | year | range
+	Expr        string    // 命中的原始表达
+}
+
+func (s TimeScope) StartDate() string { return s.Start.Format("2006-01-02") }
+func (s TimeScope) EndDate() string   { return s.End.Format("2006-01-02") }
+
+func (s TimeScope) String() string {
+	return fmt.Sprintf("%s ~ %s（%s，“%s”）", s.StartDate(), s.EndDate(), s.Granularity, s.Expr)
+}
+
+// overlaps：summary 的 [start, end] 是否与区间重叠
+func (s TimeScope) overlaps(startDate, endDate string) bool {
+	return endDate >= s.StartDate() && startDate <= s.EndDate()
+}
+
+// 粒度 → 类型偏好
+var scopeTypeBoosts = map[string]map[string]float64{
+	"day":     {"daily": 1.20},
+	"week":    {"weekly": 1.20, "daily": 1.05},
+	"month":   {"monthly": 1.20, "weekly": 1.05},
+	"quarter": {"monthly": 1.25, "weekly": 1.05},
+	"year":    {"monthly": 1.25},
+	"range":   {"daily": 1.05, "weekly": 1.05},
+}
+
+func scopeTypeBoost(s *TimeScope, typ string) float64 {
+	if s == nil {
+		return 1
+	}
+	if b, ok := scopeTypeBoosts[s.Granularity][typ]; ok {
+		return b
+	}
+	return 1
+}
+
+type timeRule struct {
+	re      *regexp.Regexp
+	resolve func(m []string, now time.Time) (TimeScope, bool)
+}
+
+var monthNames = map[string]time.Month{
+	"january": 1, "jan": 1, "february": 2, "feb": 2, "march": 3, "mar": 3,
+	"april": 4, "apr": 4, "may": 5, "june": 6, "jun": 6, "july": 7, "jul": 7,
+	"august": 8, "aug": 8, "september": 9, "sep": 9, "sept": 9, "october": 10, "oct": 10,
+	"november": 11, "nov": 11, "december": 12, "dec": 12,
+}
+
+const monthNamePattern = `january|february|march|april|may|june|july|august|september|october|november|december|jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec`
+
+// 规则按“越具体越靠前”排列；只取第一个命中的表达
+var timeRules = []timeRule{
+	// ---------- 精确日期 / ISO 周 / 年月 ----------
+	{regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})`), func(m []string, now time.Time) (TimeScope, bool) {
+		return dayScope(atoi(m[1]), time.Month(atoi(m[2])), atoi(m[3]), now.Location())
+	}},
+	{regexp.MustCompile(`(\d{4})-W(\d{2})`), func(m []string, now time.Time) (TimeScope, bool) {
+		s, e, ok := isoWeekRange(atoi(m[1]), atoi(m[2]), now.Location())
+		return TimeScope{Start: s, End: e, Granularity: "week"}, ok
+	}},
+	{regexp.MustCompile(`(\d{4})年(\d{1,2})月(\d{1,2})[日号]`), func(m []string, now time.Time) (TimeScope, bool) {
+		return dayScope(atoi(m[1]), time.Month(atoi(m[2])), atoi(m[3]), now.Location())
+	}},
+	{regexp.MustCompile(`(\d{4})-(\d{2})\b`), func(m []string, now time.Time) (TimeScope, bool) {
+		return monthScope(atoi(m[1]), time.Month(atoi(m[2])), now.Location())
+	}},
+	{regexp.MustCompile(`(\d{4})年(\d{1,2})月`), func(m []string, now time.Time) (TimeScope, bool) {
+		return monthScope(atoi(m[1]), time.Month(atoi(m[2])), now.Location())
+	}},
+	{regexp.MustCompile(`(\d{4})年?(?:第)?([一二三四1-4])季度|(\d{4})\s*年?\s*[Qq]([1-4])`), func(m []string, now time.Time) (TimeScope, bool) {
+		if m[1] != "" {
+			return quarterScope(atoi(m[1]), cnNum(m[2]), now.Location())
+		}
+		return quarterScope(atoi(m[3]), atoi(m[4]), now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\bq([1-4])\s*(?:of\s+)?(\d{4})\b`), func(m []string, now time.Time) (TimeScope, bool) {
+		return quarterScope(atoi(m[2]), atoi(m[1]), now.Location())
+	}},
+
+	// ---------- 节日 ----------
+	{regexp.MustCompile(`(?i)(?:the\s+)?week\s+before\s+christmas|圣诞节?前(?:的)?(?:那)?一周|圣诞节?前`), func(m []string, now time.Time) (TimeScope, bool) {
+		y := now.Year()
+		xmas := time.Date(y, 12, 25, 0, 0, 0, 0, now.Location())
+		if xmas.After(now) {
+			xmas = xmas.AddDate(-1, 0, 0)
+		}
+		return TimeScope{Start: xmas.AddDate(0, 0, -7), End: xmas.AddDate(0, 0, -1), Granularity: "week"}, true
+	}},
+
+	// ---------- 相对：天 ----------
+	{regexp.MustCompile(`(?i)the\s+day\s+before\s+yesterday|前天`), func(m []string, now time.Time) (TimeScope, bool) {
+		d := now.AddDate(0, 0, -2)
+		return dayScope(d.Year(), d.Month(), d.Day(), now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\byesterday\b|昨天|昨日`), func(m []string, now time.Time) (TimeScope, bool) {
+		d := now.AddDate(0, 0, -1)
+		return dayScope(d.Year(), d.Month(), d.Day(), now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\btoday\b|今天|今日`), func(m []string, now time.Time) (TimeScope, bool) {
+		return dayScope(now.Year(), now.Month(), now.Day(), now.Location())
+	}},
+	{regexp.MustCompile(`(?i)(?:in\s+the\s+)?(?:last|past)\s+(\d+)\s+(days?|weeks?|months?)|最近(\d+|[一二两三四五六七八九十]+)(天|周|个?星期|个?月)`), func(m []string, now time.Time) (TimeScope, bool) {
+		n, unit := atoi(m[1]), strings.ToLower(m[2])
+		if m[3] != "" {
+			n, unit = cnNum(m[3]), m[4]
+		}
+		if n <= 0 {
+			return TimeScope{}, false
+		}
+		end := dayStart(now)
+		var start time.Time
+		switch {
+		case strings.HasPrefix(unit, "day") || unit == "天":
+			start = end.AddDate(0, 0, -(n - 1))
+		case strings.HasPrefix(unit, "week") || strings.Contains(unit, "周") || strings.Contains(unit, "星期"):
+			start = end.AddDate(0, 0, -7*n+1)
+		default:
+			start = end.AddDate(0, -n, 1)
+		}
+		return TimeScope{Start: start, End: end, Granularity: "range"}, true
+	}},
+
+	// ---------- 相对：周 ----------
+	{regexp.MustCompile(`(?i)the\s+week\s+before\s+last|上上周|上上个?星期|上上个?礼拜`), func(m []string, now time.Time) (TimeScope, bool) {
+		s, e := weekRange(now.AddDate(0, 0, -14), now.Location())
+		return TimeScope{Start: s, End: e, Granularity: "week"}, true
+	}},
+	{regexp.MustCompile(`(?i)\blast\s+week\b|上周|上个?星期|上个?礼拜`), func(m []string, now time.Time) (TimeScope, bool) {
+		s, e := weekRange(now.AddDate(0, 0, -7), now.Location())
+		return TimeScope{Start: s, End: e, Granularity: "week"}, true
+	}},
+	{regexp.MustCompile(`(?i)\bthis\s+week\b|本周|这周|这个?星期|这个?礼拜`), func(m []string, now time.Time) (TimeScope, bool) {
+		s, e := weekRange(now, now.Location())
+		return TimeScope{Start: s, End: e, Granularity: "week"}, true
+	}},
+
+	// ---------- 相对：月 ----------
+	{regexp.MustCompile(`(?i)the\s+month\s+before\s+last|上上个?月`), func(m []string, now time.Time) (TimeScope, bool) {
+		d := firstOfMonth(now).AddDate(0, -2, 0)
+		return monthScope(d.Year(), d.Month(), now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\blast\s+month\b|上个?月`), func(m []string, now time.Time) (TimeScope, bool) {
+		d := firstOfMonth(now).AddDate(0, -1, 0)
+		return monthScope(d.Year(), d.Month(), now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\bthis\s+month\b|本月|这个?月`), func(m []string, now time.Time) (TimeScope, bool) {
+		return monthScope(now.Year(), now.Month(), now.Location())
+	}},
+
+	// ---------- 月份名 ----------
+	{regexp.MustCompile(`(?i)\b(last|this)?\s*(` + monthNamePattern + `)\b\.?(?:,?\s+(\d{4}))?`), func(m []string, now time.Time) (TimeScope, bool) {
+		mon := monthNames[strings.ToLower(m[2])]
+		// “may” 太常见（情态动词），必须带 last/this/年份 才算月份
+		if strings.ToLower(m[2]) == "may" && m[1] == "" && m[3] == "" {
+			return TimeScope{}, false
+		}
+		if m[3] != "" {
+			return monthScope(atoi(m[3]), mon, now.Location())
+		}
+		y := recentYearForMonth(now, mon, strings.EqualFold(m[1], "last"))
+		return monthScope(y, mon, now.Location())
+	}},
+	{regexp.MustCompile(`(去年|今年|前年)?(\d{1,2}|[一二三四五六七八九十]{1,3})月份?`), func(m []string, now time.Time) (TimeScope, bool) {
+		mon := time.Month(cnNum(m[2]))
+		if mon < 1 || mon > 12 {
+			return TimeScope{}, false
+		}
+		switch m[1] {
+		case "去年":
+			return monthScope(now.Year()-1, mon, now.Location())
+		case "前年":
+			return monthScope(now.Year()-2, mon, now.Location())
+		case "今年":
+			return monthScope(now.Year(), mon, now.Location())
+		}
+		return monthScope(recentYearForMonth(now, mon, false), mon, now.Location())
+	}},
+
+	// ---------- 季度 ----------
+	{regexp.MustCompile(`(?i)\b(?:in\s+)?(?:the\s+)?(last|this)?\s*q([1-4])\b|(去年|今年)?(?:第)?([一二三四])季度`), func(m []string, now time.Time) (TimeScope, bool) {
+		q, rel := atoi(m[2]), strings.ToLower(m[1])
+		if m[4] != "" {
+			q = cnNum(m[4])
+			rel = map[string]string{"去年": "lastyear", "今年": "this"}[m[3]]
+		}
+		y := now.Year()
+		switch rel {
+		case "lastyear":
+			y--
+		case "this":
+		default:
+			// 未指定年份：取最近一个已开始的该季度
+			if time.Date(y, time.Month((q-1)*3+1), 1, 0, 0, 0, 0, now.Location()).After(now) {
+				y--
+			} else if rel == "last" {
+				cur := (int(now.Month())-1)/3 + 1
+				if q >= cur {
+					y--
+				}
+			}
+		}
+		return quarterScope(y, q, now.Location())
+	}},
+
+	// ---------- 年 ----------
+	{regexp.MustCompile(`(?i)\blast\s+year\b|去年`), func(m []string, now time.Time) (TimeScope, bool) {
+		return yearScope(now.Year()-1, now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\bthis\s+year\b|今年`), func(m []string, now time.Time) (TimeScope, bool) {
+		return yearScope(now.Year(), now.Location())
+	}},
+	{regexp.MustCompile(`前年`), func(m []string, now time.Time) (TimeScope, bool) {
+		return yearScope(now.Year()-2, now.Location())
+	}},
+	{regexp.MustCompile(`(?i)\bin\s+((?:19|20)\d{2})\b|((?:19|20)\d{2})年`), func(m []string, now time.Time) (TimeScope, bool) {
+		y := m[1]
+		if y == "" {
+			y = m[2]
+		}
+		return yearScope(atoi(y), now.Location())
+	}},
+}
+
+// parseTimeScope：识别第一个时间表达，返回区间与去掉该表达后的问题
+func parseTimeScope(text string, now time.Time) (*TimeScope, string) {
+	type match struct {
+		at    int
+		end   int
+		scope TimeScope
+	}
+	var best *match
+
+	for _, r := range timeRules {
+		for _, loc := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			m := make([]string, len(loc)/2)
+			for i := range m {
+				if loc[2*i] >= 0 {
+					m[i] = text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			s, ok := r.resolve(m, now)
+			if !ok {
+				continue
+			}
+			// 同一位置优先更早的规则（更具体）；否则取最靠前的表达
+			if best == nil || loc[0] < best.at {
+				s.Expr = strings.TrimSpace(m[0])
+				best = &match{at: loc[0], end: loc[1], scope: s}
+			}
+			break
+		}
+	}
+
+	if best == nil {
+		return nil, text
+	}
+
+	rest := strings.TrimSpace(text[:best.at] + " " + text[best.end:])
+	rest = strings.Join(strings.Fields(rest), " ")
+	rest = strings.NewReplacer(" ?", "?", " ？", "？", " ,", ",").Replace(rest)
+	if rest == "" {
+		rest = text
+	}
+	return &best.scope, rest
+}
+
+/*
+========================
+Helpers
+========================
+*/
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// cnNum：支持阿拉伯数字与 一..十二 / 两
+func cnNum(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	digits := map[rune]int{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	r := []rune(s)
+	switch {
+	case len(r) == 1 && r[0] == '十':
+		return 10
+	case len(r) == 1:
+		return digits[r[0]]
+	case len(r) == 2 && r[0] == '十':
+		return 10 + digits[r[1]]
+	case len(r) == 2 && r[1] == '十':
+		return digits[r[0]] * 10
+	case len(r) == 3 && r[1] == '十':
+		return digits[r[0]]*10 + digits[r[2]]
+	}
+	return 0
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func dayScope(y int, m time.Month, d int, loc *time.Location) (TimeScope, bool) {
+	t := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	if t.Month() != m || t.Day() != d {
+		return TimeScope{}, false
+	}
+	return TimeScope{Start: t, End: t, Granularity: "day"}, true
+}
+
+func monthScope(y int, m time.Month, loc *time.Location) (TimeScope, bool) {
+	if m < 1 || m > 12 || y < 1900 {
+		return TimeScope{}, false
+	}
+	s, e := monthRange(time.Date(y, m, 1, 0, 0, 0, 0, loc), loc)
+	return TimeScope{Start: s, End: e, Granularity: "month"}, true
+}
+
+func quarterScope(y, q int, loc *time.Location) (TimeScope, bool) {
+	if q < 1 || q > 4 {
+		return TimeScope{}, false
+	}
+	s := time.Date(y, time.Month((q-1)*3+1), 1, 0, 0, 0, 0, loc)
+	e := s.AddDate(0, 3, -1)
+	return TimeScope{Start: s, End: e, Granularity: "quarter"}, true
+}
+
+func yearScope(y int, loc *time.Location) (TimeScope, bool) {
+	s := time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	e := time.Date(y, 12, 31, 0, 0, 0, 0, loc)
+	return TimeScope{Start: s, End: e, Granularity: "year"}, true
+}
+
+func isoWeekRange(year, week int, loc *time.Location) (time.Time, time.Time, bool) {
+	if week < 1 || week > 53 {
+		return time.Time{}, time.Time{}, false
+	}
+	// 1 月 4 日必定在第 1 周
+	ref := time.Date(year, 1, 4, 0, 0, 0, 0, loc).AddDate(0, 0, (week-1)*7)
+	s, e := weekRange(ref, loc)
+	if y, w := s.ISOWeek(); y != year || w != week {
+		return time.Time{}, time.Time{}, false
+	}
+	return s, e, true
+}
+
+// recentYearForMonth：最近一个“已开始”的该月份；last=true 时不含当月
+func recentYearForMonth(now time.Time, m time.Month, last bool) int {
+	y := now.Year()
+	if m > now.Month() || (last && m == now.Month()) {
+		y--
+	}
+	return y
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeScope(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 15, 0, 0, 0, loc)
+	}
+	mid := at(2025, 5, 14) // 周三
+
+	tests := []struct {
+		name        string
+		text        string
+		now         time.Time
+		start, end  string
+		granularity string
+		rest        string
+	}{
+		{"english month name", "what did I do last March", mid, "2025-03-01", "2025-03-31", "month", "what did I do"},
+		{"english month name before its start", "what did I do last March", at(2025, 2, 10), "2024-03-01", "2024-03-31", "month", "what did I do"},
+		{"last december in january", "plans from last December", at(2025, 1, 10), "2024-12-01", "2024-12-31", "month", "plans from"},
+		{"may is not a month alone", "I may go", mid, "", "", "", "I may go"},
+
+		{"chinese last month", "上个月我在忙什么", mid, "2025-04-01", "2025-04-30", "month", "我在忙什么"},
+		{"chinese last month rolls over the year", "上个月我在忙什么", at(2025, 1, 15), "2024-12-01", "2024-12-31", "month", "我在忙什么"},
+		{"chinese month before last", "上上个月的计划", mid, "2025-03-01", "2025-03-31", "month", "的计划"},
+		{"chinese year and month", "2025年3月的计划", mid, "2025-03-01", "2025-03-31", "month", "的计划"},
+		{"chinese last year month", "去年3月的计划", mid, "2024-03-01", "2024-03-31", "month", "的计划"},
+
+		{"quarter not started yet", "what happened in Q3", mid, "2024-07-01", "2024-09-30", "quarter", "what happened"},
+		{"quarter already started", "what happened in Q1", mid, "2025-01-01", "2025-03-31", "quarter", "what happened"},
+
+		{"iso date", "2025-03-02 的对话", mid, "2025-03-02", "2025-03-02", "day", "的对话"},
+		{"yesterday rolls over the year", "昨天聊了什么", at(2025, 1, 1), "2024-12-31", "2024-12-31", "day", "聊了什么"},
+		{"last week", "what did I fix last week?", mid, "2025-05-05", "2025-05-11", "week", "what did I fix?"},
+
+		{"earliest expression wins", "2024-06 and last month", mid, "2024-06-01", "2024-06-30", "month", "and last month"},
+		{"no time expression", "how is the sqlite migration going", mid, "", "", "", "how is the sqlite migration going"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, rest := parseTimeScope(tt.text, tt.now)
+			if rest != tt.rest {
+				t.Errorf("rest = %q, want %q", rest, tt.rest)
+			}
+			if tt.start == "" {
+				if s != nil {
+					t.Fatalf("got scope %s, want none", s)
+				}
+				return
+			}
+			if s == nil {
+				t.Fatalf("got no scope, want %s ~ %s", tt.start, tt.end)
+			}
+			if s.StartDate() != tt.start || s.EndDate() != tt.end || s.Granularity != tt.granularity {
+				t.Errorf("got %s ~ %s (%s), want %s ~ %s (%s)",
+					s.StartDate(), s.EndDate(), s.Granularity, tt.start, tt.end, tt.granularity)
+			}
+		})
+	}
+}
@@ -16,6 +16,9 @@ User Fact Extraction (V2)
 type RawLine struct {
 	Role    string
 	Content string
+	Kind    string // 记录类型（旧记录为空）
+	Session string // 会话 id（旧记录为空）
+	Turn    int    // 会话内的轮次
 }
 
 /*
@@ -150,16 +153,18 @@ func RememberFact(lw *LogWriter, cfg Config, db *sql.DB, content string) error {
 
 	// 1️⃣ 构造“第一人称事实陈述”
 	userText := "我确认一个事实：" + content
-	_ = lw.WriteRecord(map[string]string{
-		"role":    "user",
-		"content": userText,
+	_ = lw.WriteRecord(LogRecord{
+		Role:    "user",
+		Kind:    recordKindRemember,
+		Content: userText,
 	})
 
 	// 2️⃣ 构造 assistant 的“确认复述”
 	assistantText := "我理解了，你提到" + content
-	_ = lw.WriteRecord(map[string]string{
-		"role":    "assistant",
-		"content": assistantText,
+	_ = lw.WriteRecord(LogRecord{
+		Role:    "assistant",
+		Kind:    recordKindRemember,
+		Content: assistantText,
 	})
 
 	return nil
@@ -172,16 +177,18 @@ func ForgetFact(lw *LogWriter, cfg Config, db *sql.DB, content string) error {
 
 	// 1️⃣ 用户显式撤回事实（第一人称）
 	userText := "我撤回之前的事实：" + content
-	_ = lw.WriteRecord(map[string]string{
-		"role":    "user",
-		"content": userText,
+	_ = lw.WriteRecord(LogRecord{
+		Role:    "user",
+		Kind:    recordKindForget,
+		Content: userText,
 	})
 
 	// 2️⃣ assistant 明确确认撤回
 	assistantText := "我理解了，你明确表示之前关于「" + content + "」的事实不再成立。"
-	_ = lw.WriteRecord(map[string]string{
-		"role":    "assistant",
-		"content": assistantText,
+	_ = lw.WriteRecord(LogRecord{
+		Role:    "assistant",
+		Kind:    recordKindForget,
+		Content: assistantText,
 	})
 
 	return nil
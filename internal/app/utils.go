@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -11,6 +12,7 @@ func mustEnsureDirs(cfg Config) {
 	_ = os.MkdirAll(cfg.LogDir, 0755)
 	_ = os.MkdirAll(cfg.ArchiveDir, 0755)
 	_ = os.MkdirAll(cfg.PromptDir, 0755)
+	_ = os.MkdirAll(cfg.RecallDir, 0755)
 	_ = os.MkdirAll(filepath.Dir(cfg.DBPath), 0755)
 }
 
@@ -47,3 +49,16 @@ func sanitizeUTF8(s string) string {
 	}
 	return string([]rune(s))
 }
+
+// estimateTokens：粗略估算 token 数（CJK 约 1 字 1 token，其它约 4 字符 1 token）
+func estimateTokens(s string) int {
+	var cjk, other int
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}